@@ -0,0 +1,33 @@
+package handlers
+
+import "github.com/Solar-2020/Group-Backend/pkg/models"
+
+// routeActions - действия, право на которые проверяется перед вызовом обработчика маршрута.
+var routeActions = map[string]int{
+	routeKey("GET", "/api/group/membership/:groupID"): models.ActionViewMembers,
+	routeKey("PUT", "/api/group/membership/:groupID"): models.ActionInvite,
+	routeKey("POST", "/api/group/membership"):         models.ActionEditRole,
+	routeKey("DELETE", "/api/group/membership"):       models.ActionExpel,
+
+	routeKey("PUT", "/api/group/invitation/:groupID"):    models.ActionInvite,
+	routeKey("DELETE", "/api/group/invitation/:groupID"): models.ActionInvite,
+
+	routeKey("PUT", "/api/group/invite/:groupID"): models.ActionManageLinks,
+	routeKey("DELETE", "/api/group/invite"):       models.ActionManageLinks,
+	routeKey("GET", "/api/group/invite/list"):     models.ActionManageLinks,
+
+	routeKey("GET", "/api/group/join/requests/:groupID"):    models.ActionManageJoins,
+	routeKey("POST", "/api/group/join/requests/:groupID"):   models.ActionManageJoins,
+	routeKey("DELETE", "/api/group/join/requests/:groupID"): models.ActionManageJoins,
+
+	routeKey("GET", "/api/group/roles/:groupID"):    models.ActionViewGroup,
+	routeKey("POST", "/api/group/roles/:groupID"):   models.ActionManageRoles,
+	routeKey("PUT", "/api/group/roles/:groupID"):    models.ActionManageRoles,
+	routeKey("DELETE", "/api/group/roles/:groupID"): models.ActionManageRoles,
+
+	routeKey("GET", "/api/group/audit/:groupID"): models.ActionViewAudit,
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
@@ -3,6 +3,7 @@ package groupHandler
 import (
 	httputils "github.com/Solar-2020/GoUtils/http"
 	"github.com/Solar-2020/Group-Backend/internal/services/group"
+	"github.com/Solar-2020/Group-Backend/pkg/models"
 	"github.com/valyala/fasthttp"
 )
 
@@ -10,10 +11,17 @@ type Handler interface {
 	Create(ctx *fasthttp.RequestCtx)
 	Update(ctx *fasthttp.RequestCtx)
 	Delete(ctx *fasthttp.RequestCtx)
+	Restore(ctx *fasthttp.RequestCtx)
+	GetDeletedList(ctx *fasthttp.RequestCtx)
 	Get(ctx *fasthttp.RequestCtx)
 	GetList(ctx *fasthttp.RequestCtx)
+	CheckSlug(ctx *fasthttp.RequestCtx)
+	GetPreview(ctx *fasthttp.RequestCtx)
+	Discover(ctx *fasthttp.RequestCtx)
+	SearchGroups(ctx *fasthttp.RequestCtx)
 	InternalGetList(ctx *fasthttp.RequestCtx)
 	InternalGetPermission(ctx *fasthttp.RequestCtx)
+	InternalPreviewTemplate(ctx *fasthttp.RequestCtx)
 	InternalCheckPermission(ctx *fasthttp.RequestCtx)
 	GetMembershipList(ctx *fasthttp.RequestCtx)
 	Invite(ctx *fasthttp.RequestCtx)
@@ -23,6 +31,24 @@ type Handler interface {
 	AddLink(ctx *fasthttp.RequestCtx)
 	RemoveLink(ctx *fasthttp.RequestCtx)
 	ListLinks(ctx *fasthttp.RequestCtx)
+	GetRoles(ctx *fasthttp.RequestCtx)
+	CreateRole(ctx *fasthttp.RequestCtx)
+	UpdateRole(ctx *fasthttp.RequestCtx)
+	DeleteRole(ctx *fasthttp.RequestCtx)
+	ProposeTransfer(ctx *fasthttp.RequestCtx)
+	GetTransferList(ctx *fasthttp.RequestCtx)
+	AcceptTransfer(ctx *fasthttp.RequestCtx)
+	CancelTransfer(ctx *fasthttp.RequestCtx)
+	GetJoinRequests(ctx *fasthttp.RequestCtx)
+	GetUserJoinRequests(ctx *fasthttp.RequestCtx)
+	ApproveJoinRequest(ctx *fasthttp.RequestCtx)
+	RejectJoinRequest(ctx *fasthttp.RequestCtx)
+	GetUserInvitations(ctx *fasthttp.RequestCtx)
+	AcceptInvitation(ctx *fasthttp.RequestCtx)
+	DeclineInvitation(ctx *fasthttp.RequestCtx)
+	RevokeInvitation(ctx *fasthttp.RequestCtx)
+	ResendInvitation(ctx *fasthttp.RequestCtx)
+	GetAuditLog(ctx *fasthttp.RequestCtx)
 }
 
 type handler struct {
@@ -60,13 +86,13 @@ func (h *handler) Create(ctx *fasthttp.RequestCtx) {
 }
 
 func (h *handler) Update(ctx *fasthttp.RequestCtx) {
-	group, userID, err := h.groupTransport.UpdateDecode(ctx)
+	group, userID, requestID, err := h.groupTransport.UpdateDecode(ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	groupReturn, err := h.groupService.Update(group, userID)
+	groupReturn, err := h.groupService.Update(group, userID, requestID)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
@@ -80,13 +106,13 @@ func (h *handler) Update(ctx *fasthttp.RequestCtx) {
 }
 
 func (h *handler) Delete(ctx *fasthttp.RequestCtx) {
-	groupID, userID, err := h.groupTransport.DeleteDecode(ctx)
+	groupID, userID, requestID, err := h.groupTransport.DeleteDecode(ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	group, err := h.groupService.Delete(groupID, userID)
+	group, err := h.groupService.Delete(groupID, userID, requestID)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
@@ -99,14 +125,59 @@ func (h *handler) Delete(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+func (h *handler) Restore(ctx *fasthttp.RequestCtx) {
+	groupID, userID, requestID, err := h.groupTransport.RestoreDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	group, err := h.groupService.Restore(groupID, userID, requestID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(group, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) GetDeletedList(ctx *fasthttp.RequestCtx) {
+	userID, err := h.groupTransport.GetDeletedListDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetDeletedList(userID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
 func (h *handler) Get(ctx *fasthttp.RequestCtx) {
-	groupID, userID, err := h.groupTransport.GetDecode(ctx)
+	request, err := h.groupTransport.GetDecode(ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	group, err := h.groupService.Get(groupID, userID)
+	var group models.Group
+	if request.Slug != "" {
+		group, err = h.groupService.GetBySlug(request.Slug, request.UserID)
+	} else {
+		group, err = h.groupService.Get(request.Group, request.UserID)
+	}
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
@@ -119,20 +190,100 @@ func (h *handler) Get(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+func (h *handler) GetPreview(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.GetDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetPreview(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) Discover(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.DiscoverDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.Discover(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) SearchGroups(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.SearchGroupsDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.SearchGroups(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) CheckSlug(ctx *fasthttp.RequestCtx) {
+	slug, err := h.groupTransport.CheckSlugDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.CheckSlug(slug)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
 func (h *handler) GetList(ctx *fasthttp.RequestCtx) {
-	userID, groupID, err := h.groupTransport.GetListDecode(ctx)
+	request, err := h.groupTransport.GetListDecode(ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	groupList, err := h.groupService.GetList(groupID, userID)
+	groupList, page, err := h.groupService.GetList(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	err = h.groupTransport.GetListEncode(groupList, ctx)
+	err = h.groupTransport.GetListEncode(groupList, page, ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
@@ -140,19 +291,19 @@ func (h *handler) GetList(ctx *fasthttp.RequestCtx) {
 }
 
 func (h *handler) InternalGetList(ctx *fasthttp.RequestCtx) {
-	userID, groupID, err := h.groupTransport.InternalGetListDecode(ctx)
+	request, err := h.groupTransport.InternalGetListDecode(ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	groupList, err := h.groupService.GetList(groupID, userID)
+	groupList, page, err := h.groupService.InternalGetList(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	err = h.groupTransport.InternalGetListEncode(groupList, ctx)
+	err = h.groupTransport.InternalGetListEncode(groupList, page, ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
@@ -199,6 +350,26 @@ func (h *handler) InternalCheckPermission(ctx *fasthttp.RequestCtx) {
 	}
 }
 
+func (h *handler) InternalPreviewTemplate(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.PreviewTemplateDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.PreviewTemplate(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = h.groupTransport.PreviewTemplateEncode(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
 //func (h *handler) GetListInternal(ctx *fasthttp.RequestCtx) {
 //	userID, groupID, err := h.groupTransport.GetListDecode(ctx)
 //	if err != nil {
@@ -228,18 +399,6 @@ func (h *handler) Invite(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	role, err := h.groupService.GetUserRole(request.Group, request.CreatorID)
-	if err != nil {
-		h.errorWorker.ServeJSONError(ctx, err)
-		return
-	}
-
-	if !(role.RoleID == 1 || role.RoleID == 2) {
-		h.errorWorker.ServeJSONError(ctx, err)
-		return
-
-	}
-
 	response, err := h.groupService.Invite(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
@@ -260,11 +419,6 @@ func (h *handler) EditRole(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	//err = h.groupService.CheckPermission(models2.Group{ID: request.Group}, models2.ActionEditRole)
-	//if err != nil {
-	//	h.handleError(err, ctx)
-	//	return
-	//}
 	response, err := h.groupService.ChangeRole(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
@@ -285,12 +439,6 @@ func (h *handler) Expel(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	//err = h.groupService.CheckPermission(models2.Group{ID: request.Group}, models2.ActionExpel)
-	//if err != nil {
-	//	h.handleError(err, ctx)
-	//	return
-	//}
-
 	response, err := h.groupService.ExpelUser(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
@@ -305,19 +453,19 @@ func (h *handler) Expel(ctx *fasthttp.RequestCtx) {
 }
 
 func (h *handler) GetMembershipList(ctx *fasthttp.RequestCtx) {
-	userID, groupID, err := h.groupTransport.GetMembershipListDecode(ctx)
+	request, err := h.groupTransport.GetMembershipListDecode(ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	response, err := h.groupService.GetMembershipList(groupID, userID)
+	response, page, err := h.groupService.GetMembershipList(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
 
-	err = h.groupTransport.GetMembershipListEncode(response, ctx)
+	err = h.groupTransport.GetMembershipListEncode(response, page, ctx)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
@@ -349,11 +497,6 @@ func (h *handler) AddLink(ctx *fasthttp.RequestCtx) {
 		h.errorWorker.ServeJSONError(ctx, err)
 		return
 	}
-	//err = h.groupService.CheckPermission(models2.Group{ID: request.Group}, models2.ActionExpel)
-	//if err != nil {
-	//	h.handleError(err, ctx)
-	//	return
-	//}
 	response, err := h.groupService.AddGroupInviteLink(request, userId)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
@@ -373,11 +516,6 @@ func (h *handler) RemoveLink(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	//err = h.groupService.CheckPermission(models2.Group{ID: request.Group}, models2.ActionExpel)
-	//if err != nil {
-	//	h.handleError(err, ctx)
-	//	return
-	//}
 	response, err := h.groupService.RemoveGroupInviteLink(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
@@ -397,12 +535,6 @@ func (h *handler) ListLinks(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	//err = h.groupService.CheckPermission(models2.Group{ID: request.Group}, models2.ActionExpel)
-	//if err != nil {
-	//	h.handleError(err, ctx)
-	//	return
-	//}
-
 	response, err := h.groupService.ListGroupInviteLink(request)
 	if err != nil {
 		h.errorWorker.ServeJSONError(ctx, err)
@@ -415,3 +547,363 @@ func (h *handler) ListLinks(ctx *fasthttp.RequestCtx) {
 		return
 	}
 }
+
+func (h *handler) GetRoles(ctx *fasthttp.RequestCtx) {
+	userID, groupID, err := h.groupTransport.GetRolesDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetRoles(groupID, userID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) CreateRole(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.CreateRoleDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.CreateRole(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) UpdateRole(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.UpdateRoleDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.UpdateRole(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) DeleteRole(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.DeleteRoleDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.DeleteRole(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) ProposeTransfer(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.ProposeTransferDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.ProposeTransfer(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) GetTransferList(ctx *fasthttp.RequestCtx) {
+	userID, err := h.groupTransport.GetTransferListDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetTransferList(userID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) AcceptTransfer(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.TransferActionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.AcceptTransfer(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) CancelTransfer(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.TransferActionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.CancelTransfer(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) GetJoinRequests(ctx *fasthttp.RequestCtx) {
+	userID, groupID, err := h.groupTransport.GetJoinRequestsDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetJoinRequests(groupID, userID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) GetUserJoinRequests(ctx *fasthttp.RequestCtx) {
+	userID, err := h.groupTransport.GetUserJoinRequestsDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetUserJoinRequests(userID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) ApproveJoinRequest(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.JoinRequestDecisionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.ApproveJoinRequest(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) RejectJoinRequest(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.JoinRequestDecisionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.RejectJoinRequest(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) GetUserInvitations(ctx *fasthttp.RequestCtx) {
+	userID, err := h.groupTransport.GetUserInvitationsDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.GetUserInvitations(userID)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) AcceptInvitation(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.InvitationActionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.AcceptInvitation(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) DeclineInvitation(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.InvitationActionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.DeclineInvitation(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) RevokeInvitation(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.InvitationActionDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.RevokeInvitation(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) ResendInvitation(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.ResendInvitationDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, err := h.groupService.ResendInvitation(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = httputils.EncodeDefault(response, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
+
+func (h *handler) GetAuditLog(ctx *fasthttp.RequestCtx) {
+	request, err := h.groupTransport.AuditDecode(ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	response, page, err := h.groupService.GetAuditLog(request)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+
+	err = h.groupTransport.AuditEncode(response, page, ctx)
+	if err != nil {
+		h.errorWorker.ServeJSONError(ctx, err)
+		return
+	}
+}
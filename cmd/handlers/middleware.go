@@ -1,33 +1,67 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	auth "github.com/Solar-2020/Authorization-Backend/pkg/client"
+	httputils "github.com/Solar-2020/GoUtils/http"
+	"github.com/Solar-2020/GoUtils/http/errorWorker"
 	"github.com/Solar-2020/GoUtils/log"
+	"github.com/Solar-2020/Group-Backend/pkg/models"
 	"github.com/rs/zerolog"
 	"github.com/valyala/fasthttp"
+	"strconv"
 	"time"
 )
 
+// requestIDHeader связывает записи журнала аудита с запросом. Если клиент или прокси его не передал, он генерируется.
+const requestIDHeader = "X-Request-ID"
+
+// checkedGroupIDKey - ключ user value с группой, права в которой проверил CheckPermission. Декодеры берут группу
+// из него, а не из тела запроса, чтобы обработчик не мог работать с другой группой.
+const checkedGroupIDKey = "checkedGroupID"
+
+var (
+	errorGroupNotSpecified = errors.New("Не указана группа")
+	errorGroupMismatch     = errors.New("Группа в пути, параметрах и теле запроса не совпадает")
+)
+
 type Middleware interface {
 	Log(next fasthttp.RequestHandler) fasthttp.RequestHandler
 	ExternalAuth(next fasthttp.RequestHandler) fasthttp.RequestHandler
 	InternalAuth(next fasthttp.RequestHandler) fasthttp.RequestHandler
+	CheckPermission(actionID int, next fasthttp.RequestHandler) fasthttp.RequestHandler
+}
+
+type permissionChecker interface {
+	CheckPermission(action models.GroupAction) (err error)
 }
 
 type middleware struct {
-	log        *zerolog.Logger
-	authClient auth.Client
+	log               *zerolog.Logger
+	authClient        auth.Client
+	permissionChecker permissionChecker
 }
 
-func NewMiddleware(log *zerolog.Logger, authClient auth.Client) Middleware {
+func NewMiddleware(log *zerolog.Logger, authClient auth.Client, permissionChecker permissionChecker) Middleware {
 	return &middleware{
-		log:        log,
-		authClient: authClient,
+		log:               log,
+		authClient:        authClient,
+		permissionChecker: permissionChecker,
 	}
 }
 
 func (m middleware) Log(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
+		requestID := string(ctx.Request.Header.Peek(requestIDHeader))
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		ctx.SetUserValue("requestID", requestID)
+		ctx.Response.Header.Set(requestIDHeader, requestID)
+
 		logger := log.NewLog()
 		log.Set(ctx, &logger)
 		logger.Println(ctx, "Start new request: ", ctx.Request.URI())
@@ -78,7 +112,113 @@ func (m middleware) InternalAuth(next fasthttp.RequestHandler) fasthttp.RequestH
 	}
 }
 
+func (m middleware) CheckPermission(actionID int, next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		userID, ok := ctx.UserValue("userID").(int)
+		if !ok {
+			ServeUnAuthorizationError(ctx)
+			return
+		}
+
+		groupID, err := groupIDFromRequest(ctx)
+		if err == errorGroupMismatch {
+			ctx.SetUserValue("error", err)
+			ServeBadRequestError(ctx, err)
+			return
+		}
+		if err != nil {
+			ctx.SetUserValue("error", err)
+			ServeForbiddenError(ctx, errorGroupNotSpecified)
+			return
+		}
+
+		err = m.permissionChecker.CheckPermission(models.GroupAction{
+			GroupID:  groupID,
+			UserID:   userID,
+			ActionID: actionID,
+		})
+		if err != nil {
+			ctx.SetUserValue("error", err)
+			ServeForbiddenError(ctx, err)
+			return
+		}
+		ctx.SetUserValue(checkedGroupIDKey, groupID)
+		next(ctx)
+	}
+}
+
+// groupIDFromRequest ищет группу в параметрах пути, query-параметрах и теле запроса.
+// Если группа указана в нескольких местах, значения должны совпадать.
+func groupIDFromRequest(ctx *fasthttp.RequestCtx) (groupID int, err error) {
+	found := make([]int, 0, 4)
+	if ctx.UserValue("groupID") != nil {
+		id, err := httputils.GetUrlParamInt(ctx, "groupID")
+		if err != nil {
+			return 0, err
+		}
+		found = append(found, id)
+	}
+
+	for _, name := range []string{"groupId", "group_id"} {
+		if value := ctx.QueryArgs().Peek(name); value != nil {
+			id, err := strconv.Atoi(string(value))
+			if err != nil {
+				return 0, err
+			}
+			found = append(found, id)
+		}
+	}
+
+	// Тело, которое не разбирается как JSON, отклонит декодер обработчика.
+	var body struct {
+		Group int `json:"group"`
+	}
+	if len(ctx.Request.Body()) > 0 && json.Unmarshal(ctx.Request.Body(), &body) == nil && body.Group != 0 {
+		found = append(found, body.Group)
+	}
+
+	if len(found) == 0 || found[0] == 0 {
+		return 0, errorGroupNotSpecified
+	}
+	for _, id := range found[1:] {
+		if id != found[0] {
+			return 0, errorGroupMismatch
+		}
+	}
+	return found[0], nil
+}
+
+func ServeForbiddenError(ctx *fasthttp.RequestCtx, err error) {
+	body, marshalErr := json.Marshal(errorWorker.ServeError{Error: err.Error()})
+	if marshalErr != nil {
+		ctx.Response.Header.SetStatusCode(fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusForbidden)
+	ctx.SetBody(body)
+}
+
+func ServeBadRequestError(ctx *fasthttp.RequestCtx, err error) {
+	body, marshalErr := json.Marshal(errorWorker.ServeError{Error: err.Error()})
+	if marshalErr != nil {
+		ctx.Response.Header.SetStatusCode(fasthttp.StatusInternalServerError)
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusBadRequest)
+	ctx.SetBody(body)
+}
+
 func ServeUnAuthorizationError(ctx *fasthttp.RequestCtx) {
 	ctx.Response.Header.SetStatusCode(fasthttp.StatusUnauthorized)
 	return
 }
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
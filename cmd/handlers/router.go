@@ -4,6 +4,7 @@ import (
 	httputils "github.com/Solar-2020/GoUtils/http"
 	groupHandler "github.com/Solar-2020/Group-Backend/cmd/handlers/group"
 	"github.com/buaazp/fasthttprouter"
+	"github.com/valyala/fasthttp"
 )
 
 func NewFastHttpRouter(group groupHandler.Handler, middleware Middleware) *fasthttprouter.Router {
@@ -17,25 +18,62 @@ func NewFastHttpRouter(group groupHandler.Handler, middleware Middleware) *fasth
 	router.Handle("POST", "/api/group/group", middleware.Log(middleware.ExternalAuth(group.Create)))
 	router.Handle("PUT", "/api/group/group/:groupID", middleware.Log(middleware.ExternalAuth(group.Update)))
 	router.Handle("DELETE", "/api/group/group/:groupID", middleware.Log(middleware.ExternalAuth(group.Delete)))
+	router.Handle("POST", "/api/group/restore/:groupID", middleware.Log(middleware.ExternalAuth(group.Restore)))
+	router.Handle("GET", "/api/group/deleted", middleware.Log(middleware.ExternalAuth(group.GetDeletedList)))
 
 	router.Handle("GET", "/api/group/list", middleware.Log(middleware.ExternalAuth(group.GetList)))
+	router.Handle("GET", "/api/group/slug/:slug", middleware.Log(middleware.ExternalAuth(group.CheckSlug)))
+	router.Handle("GET", "/api/group/preview/:groupID", middleware.Log(middleware.ExternalAuth(group.GetPreview)))
+	router.Handle("GET", "/api/group/discover", middleware.Log(middleware.ExternalAuth(group.Discover)))
+	router.Handle("GET", "/api/group/search", middleware.Log(middleware.ExternalAuth(group.SearchGroups)))
 
-	router.Handle("GET", "/api/group/membership/:groupID", middleware.Log(middleware.ExternalAuth(group.GetMembershipList)))
-	router.Handle("PUT", "/api/group/membership/:groupID", middleware.Log(middleware.ExternalAuth(group.Invite)))
-	//router.Handle("PUT", "/api/group/membership/:groupID", middleware.Log(group.Invite))
-	router.Handle("POST", "/api/group/membership", middleware.Log(middleware.ExternalAuth(group.EditRole)))
-	router.Handle("DELETE", "/api/group/membership", middleware.Log(middleware.ExternalAuth(group.Expel)))
+	guarded := func(method, path string, handler fasthttp.RequestHandler) {
+		actionID, ok := routeActions[routeKey(method, path)]
+		if !ok {
+			panic("no action registered for route " + routeKey(method, path))
+		}
+		router.Handle(method, path, middleware.Log(middleware.ExternalAuth(middleware.CheckPermission(actionID, handler))))
+	}
 
-	router.Handle("PUT", "/api/group/invite/:groupID", middleware.Log(middleware.ExternalAuth(group.AddLink)))
-	router.Handle("DELETE", "/api/group/invite", middleware.Log(middleware.ExternalAuth(group.RemoveLink)))
-	router.Handle("GET", "/api/group/invite/list", middleware.Log(middleware.ExternalAuth(group.ListLinks)))
+	guarded("GET", "/api/group/membership/:groupID", group.GetMembershipList)
+	guarded("PUT", "/api/group/membership/:groupID", group.Invite)
+	guarded("POST", "/api/group/membership", group.EditRole)
+	guarded("DELETE", "/api/group/membership", group.Expel)
+
+	router.Handle("GET", "/api/group/invitation/list", middleware.Log(middleware.ExternalAuth(group.GetUserInvitations)))
+	router.Handle("POST", "/api/group/invitation", middleware.Log(middleware.ExternalAuth(group.AcceptInvitation)))
+	router.Handle("DELETE", "/api/group/invitation", middleware.Log(middleware.ExternalAuth(group.DeclineInvitation)))
+	guarded("PUT", "/api/group/invitation/:groupID", group.ResendInvitation)
+	guarded("DELETE", "/api/group/invitation/:groupID", group.RevokeInvitation)
+
+	guarded("PUT", "/api/group/invite/:groupID", group.AddLink)
+	guarded("DELETE", "/api/group/invite", group.RemoveLink)
+	guarded("GET", "/api/group/invite/list", group.ListLinks)
 	router.Handle("GET", "/api/group/invite/resolve", middleware.Log(group.Resolve))
 	router.Handle("POST", "/api/group/invite/resolve", middleware.Log(middleware.ExternalAuth(group.Resolve)))
 
+	guarded("GET", "/api/group/join/requests/:groupID", group.GetJoinRequests)
+	guarded("POST", "/api/group/join/requests/:groupID", group.ApproveJoinRequest)
+	guarded("DELETE", "/api/group/join/requests/:groupID", group.RejectJoinRequest)
+	router.Handle("GET", "/api/group/join/list", middleware.Log(middleware.ExternalAuth(group.GetUserJoinRequests)))
+
+	guarded("GET", "/api/group/roles/:groupID", group.GetRoles)
+	guarded("POST", "/api/group/roles/:groupID", group.CreateRole)
+	guarded("PUT", "/api/group/roles/:groupID", group.UpdateRole)
+	guarded("DELETE", "/api/group/roles/:groupID", group.DeleteRole)
+
+	guarded("GET", "/api/group/audit/:groupID", group.GetAuditLog)
+
+	router.Handle("PUT", "/api/group/transfer/:groupID", middleware.Log(middleware.ExternalAuth(group.ProposeTransfer)))
+	router.Handle("GET", "/api/group/transfer/list", middleware.Log(middleware.ExternalAuth(group.GetTransferList)))
+	router.Handle("POST", "/api/group/transfer", middleware.Log(middleware.ExternalAuth(group.AcceptTransfer)))
+	router.Handle("DELETE", "/api/group/transfer", middleware.Log(middleware.ExternalAuth(group.CancelTransfer)))
+
 	router.Handle("GET", "/api/internal/group/list", middleware.Log(middleware.InternalAuth(group.InternalGetList)))
 	router.Handle("GET", "/api/internal/group/permission", middleware.Log(middleware.InternalAuth(group.InternalGetPermission)))
 
 	router.Handle("GET", "/api/internal/group/check-permission", middleware.Log(middleware.InternalAuth(group.InternalCheckPermission)))
+	router.Handle("GET", "/api/internal/group/email/preview", middleware.Log(middleware.InternalAuth(group.InternalPreviewTemplate)))
 
 	return router
 }
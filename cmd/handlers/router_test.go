@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Solar-2020/GoUtils/http/errorWorker"
+	groupHandler "github.com/Solar-2020/Group-Backend/cmd/handlers/group"
+	"github.com/Solar-2020/Group-Backend/internal/services/group"
+	"github.com/Solar-2020/Group-Backend/internal/storages/groupStorage"
+	"github.com/Solar-2020/Group-Backend/pkg/models"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	testCreatorID = 1
+	testDwellerID = 2
+	testOtherID   = 3
+)
+
+// cookieAuth считает значением cookie SessionToken ID пользователя.
+type cookieAuth struct{}
+
+func (cookieAuth) GetUserIDByCookie(sessionToken string) (userID int, err error) {
+	userID, err = strconv.Atoi(sessionToken)
+	if err != nil || userID == 0 {
+		return 0, errors.New("bad session")
+	}
+	return
+}
+
+func (cookieAuth) CompareSecret(inputSecret string) (err error) {
+	return errors.New("bad secret")
+}
+
+type routerFixture struct {
+	handler fasthttp.RequestHandler
+	storage groupStorage.Storage
+	groupID int
+	otherID int
+}
+
+func newRouterFixture(t *testing.T) routerFixture {
+	storage := groupStorage.NewMemoryStorage()
+	errorWorker := errorWorker.NewErrorWorker()
+	log := zerolog.New(ioutil.Discard)
+	service := group.NewService(storage, nil, errorWorker, nil, nil, nil, &log)
+
+	own, err := service.Create(models.Group{Title: "Своя", URL: "own", CreateBy: testCreatorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.Create(models.Group{Title: "Чужая", URL: "other", CreateBy: testOtherID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.InsertUser(own.ID, testDwellerID, int(models.RoleDweller)); err != nil {
+		t.Fatal(err)
+	}
+
+	router := NewFastHttpRouter(groupHandler.NewHandler(service, group.NewTransport(), errorWorker),
+		NewMiddleware(&log, cookieAuth{}, service))
+	return routerFixture{handler: router.Handler, storage: storage, groupID: own.ID, otherID: other.ID}
+}
+
+func (f routerFixture) serve(userID int, method, uri, body string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.SetCookie("SessionToken", strconv.Itoa(userID))
+	ctx.Request.SetBodyString(body)
+	f.handler(ctx)
+	return ctx
+}
+
+func TestGuardedRoutesForbidDweller(t *testing.T) {
+	f := newRouterFixture(t)
+
+	for key, actionID := range routeActions {
+		if _, err := f.storage.SelectPermission(actionID, int(models.RoleDweller)); err == nil {
+			continue
+		}
+		parts := strings.SplitN(key, " ", 2)
+		path := strings.Replace(parts[1], ":groupID", strconv.Itoa(f.groupID), 1)
+		uri := fmt.Sprintf("%s?groupId=%d", path, f.groupID)
+		body := fmt.Sprintf(`{"group":%d}`, f.groupID)
+
+		ctx := f.serve(testDwellerID, parts[0], uri, body)
+		if ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+			t.Errorf("%s: status %d, want %d", key, ctx.Response.StatusCode(), fasthttp.StatusForbidden)
+		}
+	}
+}
+
+func TestGuardedRoutesRejectGroupMismatch(t *testing.T) {
+	f := newRouterFixture(t)
+
+	link := models.GroupInviteLink{Link: "otherlink", Author: models.AuthorPack{ID: testOtherID}, RoleID: int(models.RoleDweller)}
+	if err := f.storage.AddShortLinkToGroup(f.otherID, link); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, uri, body string
+	}{
+		{"DELETE", fmt.Sprintf("/api/group/invite?groupId=%d", f.groupID),
+			fmt.Sprintf(`{"group":%d,"links":["http://host/%s"]}`, f.otherID, link.Link)},
+		{"DELETE", fmt.Sprintf("/api/group/membership?group_id=%d", f.groupID),
+			fmt.Sprintf(`{"group":%d,"userId":%d,"userEmail":"other@mail.ru"}`, f.otherID, testOtherID)},
+		{"POST", fmt.Sprintf("/api/group/membership?groupId=%d", f.groupID),
+			fmt.Sprintf(`{"group":%d,"userId":%d,"userEmail":"other@mail.ru","role":2}`, f.otherID, testOtherID)},
+		{"PUT", fmt.Sprintf("/api/group/invite/%d", f.groupID), fmt.Sprintf(`{"group":%d}`, f.otherID)},
+		{"PUT", fmt.Sprintf("/api/group/membership/%d", f.groupID), fmt.Sprintf(`{"group":%d}`, f.otherID)},
+	}
+	for _, c := range cases {
+		ctx := f.serve(testCreatorID, c.method, c.uri, c.body)
+		if ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want %d", c.method, c.uri, ctx.Response.StatusCode(), fasthttp.StatusBadRequest)
+		}
+	}
+
+	if _, err := f.storage.SelectLinkByHash(link.Link); err != nil {
+		t.Errorf("link of the other group removed: %v", err)
+	}
+	if _, err := f.storage.SelectGroupRole(f.otherID, testOtherID); err != nil {
+		t.Errorf("member of the other group removed: %v", err)
+	}
+
+	ctx := f.serve(testCreatorID, "DELETE", "/api/group/invite",
+		fmt.Sprintf(`{"group":%d,"links":["http://host/%s"]}`, f.otherID, link.Link))
+	if ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+		t.Errorf("foreign group: status %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusForbidden)
+	}
+}
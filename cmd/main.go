@@ -8,8 +8,13 @@ import (
 	"github.com/Solar-2020/Group-Backend/cmd/handlers"
 	groupHandler "github.com/Solar-2020/Group-Backend/cmd/handlers/group"
 	"github.com/Solar-2020/Group-Backend/internal"
+	"github.com/Solar-2020/Group-Backend/internal/accountClient"
+	"github.com/Solar-2020/Group-Backend/internal/services/email"
 	"github.com/Solar-2020/Group-Backend/internal/services/group"
 	"github.com/Solar-2020/Group-Backend/internal/storages/groupStorage"
+	"github.com/Solar-2020/Group-Backend/internal/storages/migrations"
+	"github.com/Solar-2020/Group-Backend/internal/storages/outboxStorage"
+	"github.com/Solar-2020/Group-Backend/internal/tokenGenerator"
 	"github.com/kelseyhightower/envconfig"
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog"
@@ -18,6 +23,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func main() {
@@ -38,11 +44,102 @@ func main() {
 	groupDB.SetMaxIdleConns(5)
 	groupDB.SetMaxOpenConns(10)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = runMigrate(migrations.NewMigrator(groupDB), os.Args[2:], &log)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		return
+	}
+
+	if internal.Config.MigrateOnStart {
+		err = runMigrate(migrations.NewMigrator(groupDB), []string{"up"}, &log)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+			return
+		}
+	}
+
 	errorWorker := errorWorker.NewErrorWorker()
 
+	inviteTokenGenerator, err := tokenGenerator.NewGenerator(internal.Config.InviteLinkLength, internal.Config.InviteLinkAlphabet)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+		return
+	}
+
 	groupStorage := groupStorage.NewStorage(groupDB)
-	accountClient := account.NewClient(internal.Config.AccountServiceHost, internal.Config.ServerSecret)
-	groupService := group.NewService(groupStorage, accountClient, errorWorker)
+	outboxStorage := outboxStorage.NewStorage(groupDB)
+	accountClient := accountClient.NewClient(account.NewClient(internal.Config.AccountServiceHost, internal.Config.ServerSecret),
+		internal.Config.AccountLookupConcurrency)
+	inviteTemplates, err := email.NewTemplateRegistry(internal.Config.InviteLetterBasePath, internal.Config.InviteLetterDefaultLocale,
+		group.ValidateInviteTemplates)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+		return
+	}
+	logTemplateReload := func(err error) {
+		if err != nil {
+			log.Error().Str("msg", "invite templates reload failure, keeping previous version").Err(err).Send()
+			return
+		}
+		log.Info().Str("msg", "invite templates reloaded").Send()
+	}
+	if internal.Config.InviteLetterReloadInterval > 0 {
+		go inviteTemplates.Watch(time.Duration(internal.Config.InviteLetterReloadInterval)*time.Second, logTemplateReload)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logTemplateReload(inviteTemplates.Reload())
+		}
+	}()
+
+	groupService := group.NewService(groupStorage, accountClient, errorWorker, inviteTokenGenerator, outboxStorage,
+		inviteTemplates, &log)
+
+	mailer, err := email.NewMailer(email.MailerConfig{
+		Backend:      internal.Config.InviteLetterBackend,
+		SMTPHost:     internal.Config.InviteLetterHost,
+		SMTPPort:     internal.Config.InviteLetterPort,
+		SMTPSecurity: internal.Config.InviteLetterSecurity,
+		SMTPUsername: internal.Config.InviteLetterSender,
+		SMTPPassword: internal.Config.InviteLetterSenderPassword,
+		DropPath:     internal.Config.InviteLetterDropPath,
+	}, &log)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+		return
+	}
+
+	if internal.Config.DKIMPrivateKeyPath != "" {
+		dkimSigner, err := email.NewDKIMSigner(email.DKIMConfig{
+			Domain:         internal.Config.DKIMDomain,
+			Selector:       internal.Config.DKIMSelector,
+			PrivateKeyPath: internal.Config.DKIMPrivateKeyPath,
+		})
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+			return
+		}
+		mailer = email.NewDKIMMailer(mailer, dkimSigner)
+	}
+
+	emailWorker := email.NewWorker(outboxStorage, mailer, email.WorkerConfig{
+		PollInterval: time.Duration(internal.Config.InviteLetterTimespan) * time.Second,
+		MaxAttempts:  internal.Config.InviteLetterMaxRetries,
+	}, &log)
+	if internal.Config.SendInviteLetter {
+		go emailWorker.Run()
+	}
+
+	groupPurger := group.NewPurger(groupService, group.PurgerConfig{
+		Interval: time.Duration(internal.Config.GroupPurgeInterval) * time.Second,
+	}, &log)
+	if internal.Config.GroupPurgeInterval > 0 {
+		go groupPurger.Run()
+	}
 	groupTransport := group.NewTransport()
 
 	groupHandler := groupHandler.NewHandler(groupService, groupTransport, errorWorker)
@@ -52,7 +149,7 @@ func main() {
 		return
 	}
 	authClient := auth.NewClient(authURL.Host, internal.Config.ServerSecret)
-	middlewares := handlers.NewMiddleware(&log, authClient)
+	middlewares := handlers.NewMiddleware(&log, authClient, groupService)
 
 	server := fasthttp.Server{
 		Handler: handlers.NewFastHttpRouter(groupHandler, middlewares).Handler,
@@ -77,6 +174,14 @@ func main() {
 			log.Error().Str("msg", "server shutdown failure").Err(err).Send()
 		}
 
+		if internal.Config.SendInviteLetter {
+			emailWorker.Shutdown()
+		}
+		if internal.Config.GroupPurgeInterval > 0 {
+			groupPurger.Shutdown()
+		}
+		inviteTemplates.Shutdown()
+
 		//dbConnection.Shutdown()
 		log.Info().Str("msg", "goodbye").Send()
 	}(<-c)
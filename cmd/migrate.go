@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Solar-2020/Group-Backend/internal/storages/migrations"
+	"github.com/rs/zerolog"
+	"strconv"
+)
+
+// runMigrate выполняет подкоманду migrate:
+//
+//	main migrate [up]      - применить все новые миграции
+//	main migrate down [N]  - откатить N последних миграций (по умолчанию одну)
+//	main migrate status    - показать применённые и ожидающие миграции
+func runMigrate(migrator migrations.Migrator, args []string, log *zerolog.Logger) (err error) {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Info().Str("msg", "migration applied").Int("version", migration.Version).Str("name", migration.Name).Send()
+		}
+		if err == nil && len(applied) == 0 {
+			log.Info().Str("msg", "schema is up to date").Send()
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: bad steps count %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			log.Info().Str("msg", "migration reverted").Int("version", migration.Version).Str("name", migration.Name).Send()
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			event := log.Info().Int("version", status.Version).Str("name", status.Name).Bool("applied", status.Applied)
+			if status.AppliedAt != nil {
+				event = event.Time("appliedAt", *status.AppliedAt)
+			}
+			event.Send()
+		}
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown command %q, expected up, down or status", command)
+	}
+}
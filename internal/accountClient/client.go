@@ -0,0 +1,72 @@
+package accountClient
+
+import (
+	account "github.com/Solar-2020/Account-Backend/pkg/client"
+	"github.com/Solar-2020/Account-Backend/pkg/models"
+	"sync"
+)
+
+type Client interface {
+	account.Client
+	GetUsersByUids(userIDs []int) (users map[int]models.User, err error)
+}
+
+// client дополняет клиент сервиса аккаунтов пакетным запросом. У сервиса аккаунтов нет метода,
+// отдающего нескольких пользователей сразу, поэтому запросы идут параллельно, не больше concurrency одновременно.
+type client struct {
+	account.Client
+	concurrency int
+}
+
+func NewClient(accountClient account.Client, concurrency int) Client {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &client{
+		Client:      accountClient,
+		concurrency: concurrency,
+	}
+}
+
+// GetUsersByUids возвращает найденных пользователей. Тех, кого получить не удалось, в ответе нет;
+// ошибка возвращается, только если не удалось получить ни одного.
+func (c *client) GetUsersByUids(userIDs []int) (users map[int]models.User, err error) {
+	users = make(map[int]models.User, len(userIDs))
+
+	seen := make(map[int]bool, len(userIDs))
+	unique := make([]int, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !seen[userID] {
+			seen[userID] = true
+			unique = append(unique, userID)
+		}
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, c.concurrency)
+	for _, userID := range unique {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(userID int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			user, userErr := c.GetUserByUid(userID)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if userErr != nil {
+				err = userErr
+				return
+			}
+			users[userID] = user
+		}(userID)
+	}
+	wg.Wait()
+
+	if len(users) != 0 || len(unique) == 0 {
+		err = nil
+	}
+	return
+}
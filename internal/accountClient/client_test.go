@@ -0,0 +1,90 @@
+package accountClient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	account "github.com/Solar-2020/Account-Backend/pkg/client"
+	"github.com/Solar-2020/Account-Backend/pkg/models"
+)
+
+// fakeAccounts отвечает пользователями из known и считает одновременные запросы.
+type fakeAccounts struct {
+	account.Client
+	known map[int]bool
+
+	mutex    sync.Mutex
+	calls    map[int]int
+	inFlight int
+	peak     int
+}
+
+func (f *fakeAccounts) GetUserByUid(userID int) (user models.User, err error) {
+	f.mutex.Lock()
+	f.calls[userID]++
+	f.inFlight++
+	if f.inFlight > f.peak {
+		f.peak = f.inFlight
+	}
+	f.mutex.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	f.mutex.Lock()
+	f.inFlight--
+	f.mutex.Unlock()
+
+	if !f.known[userID] {
+		return user, errors.New("user not found")
+	}
+	return models.User{ID: userID}, nil
+}
+
+func TestGetUsersByUids(t *testing.T) {
+	cases := []struct {
+		name        string
+		userIDs     []int
+		known       []int
+		concurrency int
+		found       []int
+		fails       bool
+	}{
+		{"duplicates", []int{1, 2, 1, 3, 2}, []int{1, 2, 3}, 2, []int{1, 2, 3}, false},
+		{"concurrency bound", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 3,
+			[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false},
+		{"partial failure", []int{1, 2, 3}, []int{2}, 2, []int{2}, false},
+		{"total failure", []int{1, 2, 3}, nil, 2, nil, true},
+		{"empty", nil, nil, 2, nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &fakeAccounts{known: make(map[int]bool), calls: make(map[int]int)}
+			for _, userID := range c.known {
+				fake.known[userID] = true
+			}
+
+			users, err := NewClient(fake, c.concurrency).GetUsersByUids(c.userIDs)
+			if c.fails != (err != nil) {
+				t.Fatalf("error: %v", err)
+			}
+			if len(users) != len(c.found) {
+				t.Errorf("got %d users, want %d", len(users), len(c.found))
+			}
+			for _, userID := range c.found {
+				if users[userID].ID != userID {
+					t.Errorf("user %d is missing", userID)
+				}
+			}
+			for userID, calls := range fake.calls {
+				if calls != 1 {
+					t.Errorf("user %d requested %d times", userID, calls)
+				}
+			}
+			if fake.peak > c.concurrency {
+				t.Errorf("%d requests in flight, limit %d", fake.peak, c.concurrency)
+			}
+		})
+	}
+}
@@ -9,15 +9,34 @@ var (
 type configTemplate struct {
 	common.SharedConfig
 	GroupDataBaseConnectionString string `envconfig:"GROUP_DB_CONNECTION_STRING" required:"true"`
+	MigrateOnStart				bool   `envconfig:"MIGRATE_ON_START" default:"false"`
 	InviteLinkPrefix              string `envconfig:"INVITE_GROUP_PREFIX_ADDRESS" default:"http://nl-mail.ru/welcome"`
+	InviteLinkLength              int    `envconfig:"INVITE_LINK_LENGTH" default:"10"`
+	InviteLinkAlphabet            string `envconfig:"INVITE_LINK_ALPHABET" default:"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_"`
 	ServerSecret                  string `envconfig:"SERVER_SECRET" default:"Basic secret"`
 	AccountServiceHost            string `envconfig:"ACCOUNT_SERVICE_HOST" default:"develop.pay-together.ru"`
+	AccountMediaHost				string `envconfig:"ACCOUNT_MEDIA_HOST" default:"develop.pay-together.ru"`
 	SendInviteLetter				bool   `envconfig:"SEND_INVITE_LETTERS" default:"false"`
 
 	InviteLetterHost				string `envconfig:"INVITE_LETTERS_HOST" default:"smtp.mail.ru"`
+	InviteLetterPort				int    `envconfig:"INVITE_LETTERS_PORT" default:"25"`
+	InviteLetterSecurity			string `envconfig:"INVITE_LETTERS_SECURITY" default:"starttls"`
+	InviteLetterBackend				string `envconfig:"INVITE_LETTERS_BACKEND" default:"smtp"`
+	InviteLetterDropPath			string `envconfig:"INVITE_LETTERS_DROP_PATH" default:"/tmp/maildir"`
 	InviteLetterSender				string `envconfig:"INVITE_LETTERS_SENDER" default:"invite@pay-together.ru"`
 	InviteLetterSenderPassword		string `envconfig:"INVITE_LETTERS_PASSWORD" required:"true"`
 	InviteLetterBasePath			string `envconfig:"INVITE_LETTERS_BASE_PATH" default:"/templates"`
+	InviteLetterDefaultLocale		string `envconfig:"INVITE_LETTERS_DEFAULT_LOCALE" default:"ru"`
+	InviteLetterUnsubscribe			string `envconfig:"INVITE_LETTERS_UNSUBSCRIBE"`
+	InviteLetterReloadInterval		int    `envconfig:"INVITE_LETTERS_RELOAD_INTERVAL" default:"10"`
+	AccountLookupConcurrency		int    `envconfig:"ACCOUNT_LOOKUP_CONCURRENCY" default:"8"`
+	DKIMDomain						string `envconfig:"DKIM_DOMAIN"`
+	DKIMSelector					string `envconfig:"DKIM_SELECTOR"`
+	DKIMPrivateKeyPath				string `envconfig:"DKIM_PRIVATE_KEY_PATH"`
 	InviteLetterTimespan			int    `envconfig:"INVITE_LETTERS_TIMESPAN" default:"20"`
 	InviteLetterMaxRetries			int    `envconfig:"INVITE_LETTERS_MAX_RETRIES" default:"2"`
+
+	OwnershipTransferTTL			int    `envconfig:"OWNERSHIP_TRANSFER_TTL_HOURS" default:"72"`
+	GroupDeleteRetention			int    `envconfig:"GROUP_DELETE_RETENTION_HOURS" default:"720"`
+	GroupPurgeInterval				int    `envconfig:"GROUP_PURGE_INTERVAL" default:"3600"`
 }
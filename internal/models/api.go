@@ -1,23 +1,127 @@
 package models
 
-import "github.com/Solar-2020/Group-Backend/pkg/models"
+import (
+	"github.com/Solar-2020/Group-Backend/pkg/models"
+	"time"
+)
 
 // PUT /group/membership
 type InviteUserRequest struct {
 	CreatorID int               `json:"-"`
+	RequestID string            `json:"-"`
 	UserID    []int             `json:"userId"`
 	Group     int               `json:"group" validate:"required"`
 	User      []string          `json:"userEmail"`
 	Role      models.MemberRole `json:"role"`
+	Language  string            `json:"language,omitempty"`
 }
 type InviteUserResponse InviteUserRequest
 
+// GET /group/group/:groupID (groupID - числовой ID или ссылка группы)
+type GetGroupRequest struct {
+	UserID int
+	Group  int
+	Slug   string
+	// Link - пригласительная ссылка (?link=), по которой группу открывают в превью.
+	Link string
+}
+
+// GET /group/slug/:slug
+type SlugAvailabilityResponse struct {
+	Slug      string `json:"slug"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// GET /group/discover
+type DiscoverRequest struct {
+	UserID int
+	Limit  int
+	Cursor string
+}
+
+type DiscoverResponse struct {
+	Groups []models.GroupPreview `json:"groups"`
+	// NextCursor передаётся в cursor за следующей страницей, пуст на последней странице.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+const (
+	SearchScopeAll    = "all"
+	SearchScopeMember = "member"
+	SearchScopePublic = "public"
+)
+
+// GET /group/search
+type SearchGroupsRequest struct {
+	UserID int
+	Query  string `validate:"required"`
+	// Scope - SearchScopeMember (группы пользователя), SearchScopePublic (публичные) или SearchScopeAll (и те, и другие).
+	Scope  string `validate:"omitempty,oneof=all member public"`
+	Limit  int
+	Offset int
+}
+
+// SearchGroupsResponse листается смещением: порядок задаёт ts_rank, который считается заново на каждый запрос
+// и не хранится, так что курсор по нему не построить.
+type SearchGroupsResponse struct {
+	Groups     []models.GroupPreview `json:"groups"`
+	NextOffset int                   `json:"nextOffset,omitempty"`
+}
+
+const (
+	SortByTitle  = "title"
+	SortByJoined = "joined"
+	SortByRole   = "role"
+	// SortByCreated используется только журналом аудита и не принимается от клиента.
+	SortByCreated = "created"
+	// SortByMembers используется только каталогом публичных групп и не принимается от клиента.
+	SortByMembers = "members"
+
+	DefaultListLimit = 100
+	MaxListLimit     = 500
+)
+
+// ListParams - общие параметры постраничных списков. Cursor берётся из заголовка X-Next-Cursor
+// предыдущего ответа и действителен только с теми же SortBy и Desc.
+type ListParams struct {
+	Limit  int
+	Cursor string
+	SortBy string `validate:"omitempty,oneof=title joined role"`
+	Desc   bool
+	// RoleID оставляет в списке только участников (группы) с этой ролью.
+	RoleID int
+}
+
+type Page struct {
+	Total      int
+	NextCursor string
+}
+
+// GET /group/list
+// GET /internal/group/list
+type GroupListRequest struct {
+	UserID int
+	Group  int
+	ListParams
+}
+
+// GET /group/membership/:groupID
+type MembershipListRequest struct {
+	UserID      int
+	Group       int
+	WithPending bool
+	ListParams
+}
+
 // POST /group/membership
 type ChangeRoleRequest struct {
-	UserID int               `json:"userId"`
-	Group  int               `json:"group" validate:"required"`
-	User   string            `json:"userEmail" validate:"required,email"`
-	Role   models.MemberRole `json:"role"`
+	ActorID   int               `json:"-"`
+	RequestID string            `json:"-"`
+	UserID    int               `json:"userId"`
+	Group     int               `json:"group" validate:"required"`
+	User      string            `json:"userEmail" validate:"required,email"`
+	Role      models.MemberRole `json:"role"`
 }
 type ChangeRoleResponse struct {
 	Role models.MemberRole `json:"role"`
@@ -25,9 +129,11 @@ type ChangeRoleResponse struct {
 
 // DELETE /group/membership
 type ExpelUserRequest struct {
-	UserID int    `json:"userId"`
-	Group  int    `json:"group" validate:"required"`
-	User   string `json:"userEmail" validate:"email"`
+	ActorID   int    `json:"-"`
+	RequestID string `json:"-"`
+	UserID    int    `json:"userId"`
+	Group     int    `json:"group" validate:"required"`
+	User      string `json:"userEmail" validate:"email"`
 }
 type ExpelUserResponse struct {
 	User string `json:"userEmail"`
@@ -35,7 +141,11 @@ type ExpelUserResponse struct {
 
 // PUT /group/invite
 type AddInviteLinkRequest struct {
-	Group int `json:"group"`
+	RequestID string            `json:"-"`
+	Group     int               `json:"group"`
+	ExpiresAt *time.Time        `json:"expiresAt"`
+	MaxUses   int               `json:"maxUses" validate:"min=0"`
+	Role      models.MemberRole `json:"role"`
 }
 type AddInviteLinkResponse struct {
 	Group int    `json:"group"`
@@ -44,8 +154,10 @@ type AddInviteLinkResponse struct {
 
 // DELETE /group/invite
 type RemoveInviteLinkRequest struct {
-	Group int      `json:"group" validate:"required"`
-	Links []string `json:"links" validate:"required"`
+	ActorID   int      `json:"-"`
+	RequestID string   `json:"-"`
+	Group     int      `json:"group" validate:"required"`
+	Links     []string `json:"links" validate:"required"`
 }
 type RemoveInviteLinkRsponse struct {
 	Group int      `json:"group"`
@@ -54,7 +166,7 @@ type RemoveInviteLinkRsponse struct {
 
 // POST /group/invite/list
 type ListInviteLinkRequest struct {
-	Group int `json:"group" validate:"required"`
+	Group  int `json:"group" validate:"required"`
 	UserID int `json:"user"`
 }
 type ListInviteLinkResponse struct {
@@ -64,11 +176,111 @@ type ListInviteLinkResponse struct {
 
 // POST /group/invite/resolves
 type ResolveInviteLinkRequest struct {
-	Link   string `json:"link"`
-	UserID int    `json:"userId"`
+	RequestID string `json:"-"`
+	Link      string `json:"link"`
+	UserID    int    `json:"userId"`
 }
 type ResolveInviteLinkResponse struct {
-	Group int `json:"group"`
-	UserID int `json:"userId,omitempty"`
+	Group   int  `json:"group"`
+	UserID  int  `json:"userId,omitempty"`
+	Pending bool `json:"pending,omitempty"`
+}
+
+// POST /group/roles/:groupID
+type CreateRoleRequest struct {
+	UserID    int    `json:"-"`
+	RequestID string `json:"-"`
+	Group     int    `json:"group" validate:"required"`
+	Title     string `json:"title" validate:"required,max=50"`
+	Rank      int    `json:"rank" validate:"required,min=1"`
+	Actions   []int  `json:"actions"`
+}
+
+// PUT /group/roles/:groupID
+type UpdateRoleRequest struct {
+	UserID    int    `json:"-"`
+	RequestID string `json:"-"`
+	Group     int    `json:"group" validate:"required"`
+	RoleID    int    `json:"id" validate:"required"`
+	Title     string `json:"title" validate:"required,max=50"`
+	Rank      int    `json:"rank" validate:"required,min=1"`
+	Actions   []int  `json:"actions"`
+}
+
+// DELETE /group/roles/:groupID
+type DeleteRoleRequest struct {
+	UserID    int    `json:"-"`
+	RequestID string `json:"-"`
+	Group     int    `json:"group" validate:"required"`
+	RoleID    int    `json:"id" validate:"required"`
+}
+type DeleteRoleResponse struct {
+	Group  int `json:"group"`
+	RoleID int `json:"id"`
+}
+
+// PUT /group/transfer/:groupID
+type ProposeTransferRequest struct {
+	ActorID int    `json:"-"`
+	Group   int    `json:"group" validate:"required"`
+	UserID  int    `json:"userId"`
+	User    string `json:"userEmail"`
+}
+
+// POST /group/transfer
+// DELETE /group/transfer
+type TransferActionRequest struct {
+	ActorID    int    `json:"-"`
+	RequestID  string `json:"-"`
+	TransferID int    `json:"id" validate:"required"`
+}
+
+// POST /group/join/requests/:groupID
+// DELETE /group/join/requests/:groupID
+type JoinRequestDecisionRequest struct {
+	ActorID int `json:"-"`
+	// TraceID - идентификатор HTTP-запроса для журнала аудита: RequestID здесь занят номером заявки.
+	TraceID   string `json:"-"`
+	Group     int    `json:"group" validate:"required"`
+	RequestID int    `json:"id" validate:"required"`
+}
+
+// POST /group/invitation
+// DELETE /group/invitation
+// DELETE /group/invitation/:groupID
+type InvitationActionRequest struct {
+	ActorID      int    `json:"-"`
+	RequestID    string `json:"-"`
+	Group        int    `json:"-"`
+	InvitationID int    `json:"id" validate:"required"`
+}
+
+// PUT /group/invitation/:groupID
+type ResendInvitationRequest struct {
+	ActorID      int    `json:"-"`
+	RequestID    string `json:"-"`
+	Group        int    `json:"-"`
+	InvitationID int    `json:"id" validate:"required"`
+	Language     string `json:"language,omitempty"`
+}
+
+// GET /internal/group/email/preview
+type TemplatePreviewRequest struct {
+	Template string `validate:"required"`
+	Locale   string
 }
 
+type TemplatePreviewResponse struct {
+	ContentType string
+	Body        []byte
+}
+
+// GET /api/group/audit/:groupID?actor=&action=&limit=&cursor=
+// Записи отдаются от новых к старым.
+type AuditListRequest struct {
+	UserID  int
+	Group   int
+	ActorID int
+	Action  string
+	ListParams
+}
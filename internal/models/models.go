@@ -1,13 +1,66 @@
 package models
 
-import "github.com/pkg/errors"
+import (
+	"github.com/pkg/errors"
+	"time"
+)
 
 var (
-	ErrorNoMembership = errors.New("Вы не состоите в данной группе")
-	ErrorNoPermission = errors.New("У Вас не достаточно прав")
+	ErrorNoMembership  = errors.New("Вы не состоите в данной группе")
+	ErrorNoPermission  = errors.New("У Вас не достаточно прав")
+	ErrorRoleNotFound  = errors.New("Роль не найдена")
+	ErrorSystemRole    = errors.New("Системную роль нельзя изменить")
+	ErrorRoleInUse     = errors.New("Роль назначена участникам, приглашениям или ссылкам группы")
+	ErrorRoleRank      = errors.New("Недопустимый ранг роли")
+	ErrorRoleHierarchy = errors.New("Нельзя управлять участником с равной или более высокой ролью")
+	ErrorUnknownAction = errors.New("Неизвестное действие")
+	ErrorActionNotHeld = errors.New("Нельзя выдать права, которых нет у Вас")
+	ErrorLastCreator   = errors.New("Нельзя исключить или понизить последнего создателя группы")
+	ErrorNotCreator    = errors.New("Передать группу может только её создатель")
+	ErrorSelfTransfer  = errors.New("Нельзя передать группу самому себе")
+	ErrorNoTransfer    = errors.New("Запрос на передачу группы не найден или истёк")
+	ErrorStaleTransfer = errors.New("Запрос на передачу устарел: группа удалена или предложивший больше не её создатель")
+	ErrorLinkNotFound  = errors.New("Пригласительная ссылка не найдена")
+	ErrorLinkExpired   = errors.New("Срок действия пригласительной ссылки истёк")
+	ErrorLinkExhausted = errors.New("Пригласительная ссылка использована максимальное число раз")
+	ErrorBadExpiration = errors.New("Срок действия ссылки должен быть в будущем")
+	ErrorDuplicate     = errors.New("Запись уже существует")
+	ErrorNoJoinRequest = errors.New("Заявка на вступление не найдена")
+	ErrorAlreadyMember = errors.New("Вы уже состоите в данной группе")
+	ErrorNoInvitation  = errors.New("Приглашение не найдено")
+	ErrorNotInvitee    = errors.New("Приглашение отправлено другому пользователю")
+	ErrorNoTemplate    = errors.New("Шаблон не найден")
+	ErrorSlugInvalid   = errors.New("Ссылка может содержать только латинские буквы, цифры, '-' и '_' и должна начинаться с буквы")
+	ErrorSlugReserved  = errors.New("Эта ссылка зарезервирована")
+	ErrorSlugTaken     = errors.New("Группа с такой ссылкой уже существует")
+	ErrorNoGroup       = errors.New("Группа не найдена")
+	ErrorNotRestorable = errors.New("Группа не найдена среди недавно удалённых")
+	ErrorBadVisibility = errors.New("Недопустимая видимость группы")
+	ErrorBadCursor     = errors.New("Недопустимый курсор")
+	ErrorBadSort       = errors.New("Недопустимая сортировка")
 )
 
 type Permission struct {
 	RoleID   int
 	ActionID int
 }
+
+const (
+	OutboxPending = 1
+	OutboxSending = 2
+	OutboxSent    = 3
+	OutboxDead    = 4
+)
+
+// OutboxMessage - письмо, ожидающее отправки в таблице email_outbox.
+type OutboxMessage struct {
+	ID            int
+	From          string
+	To            string
+	Message       []byte
+	StatusID      int
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreateAt      time.Time
+}
@@ -0,0 +1,221 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// dkimHeaders - заголовки, которые подписываются, если они есть в письме.
+var dkimHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "List-Unsubscribe"}
+
+type DKIMConfig struct {
+	Domain         string
+	Selector       string
+	PrivateKeyPath string
+}
+
+type DKIMSigner interface {
+	Sign(message []byte) (signed []byte, err error)
+}
+
+type dkimSigner struct {
+	domain    string
+	selector  string
+	key       crypto.Signer
+	algorithm string
+}
+
+func NewDKIMSigner(config DKIMConfig) (DKIMSigner, error) {
+	if config.Domain == "" || config.Selector == "" {
+		return nil, errors.New("dkim: domain and selector are required")
+	}
+
+	content, err := ioutil.ReadFile(config.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, algorithm, err := parseDKIMKey(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dkimSigner{
+		domain:    config.Domain,
+		selector:  config.Selector,
+		key:       key,
+		algorithm: algorithm,
+	}, nil
+}
+
+func parseDKIMKey(content []byte) (key crypto.Signer, algorithm string, err error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, "", errors.New("dkim: no PEM block in private key file")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, "", fmt.Errorf("dkim: unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return
+	}
+
+	switch parsed := parsed.(type) {
+	case *rsa.PrivateKey:
+		return parsed, "rsa-sha256", nil
+	case ed25519.PrivateKey:
+		return parsed, "ed25519-sha256", nil
+	default:
+		return nil, "", errors.New("dkim: only RSA and Ed25519 keys are supported")
+	}
+}
+
+// Sign добавляет в начало письма заголовок DKIM-Signature (RFC 6376, канонизация relaxed/relaxed).
+func (s *dkimSigner) Sign(message []byte) (signed []byte, err error) {
+	header, body := splitMessage(message)
+	fields := parseHeaderFields(header)
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	signedHeaders := make([]string, 0, len(dkimHeaders))
+	hashed := bytes.NewBufferString("")
+	for _, name := range dkimHeaders {
+		field, ok := lastHeaderField(fields, name)
+		if !ok {
+			continue
+		}
+		signedHeaders = append(signedHeaders, strings.ToLower(name))
+		hashed.WriteString(relaxedHeader(field))
+		hashed.WriteString("\r\n")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.algorithm, s.domain, s.selector, time.Now().Unix(), strings.Join(signedHeaders, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+	hashed.WriteString(relaxedHeader("DKIM-Signature: " + value))
+
+	digest := sha256.Sum256(hashed.Bytes())
+	var signature []byte
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		// RFC 8463: Ed25519 подписывает SHA-256 хеш, а не сами данные.
+		signature = ed25519.Sign(key, digest[:])
+	default:
+		signature, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return
+		}
+	}
+
+	result := bytes.NewBufferString("DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(signature) + "\r\n")
+	result.Write(message)
+	return result.Bytes(), nil
+}
+
+type dkimMailer struct {
+	next   Mailer
+	signer DKIMSigner
+}
+
+// NewDKIMMailer подписывает каждое письмо перед передачей его в next.
+func NewDKIMMailer(next Mailer, signer DKIMSigner) Mailer {
+	return &dkimMailer{
+		next:   next,
+		signer: signer,
+	}
+}
+
+func (m *dkimMailer) Send(from string, to []string, message []byte) (err error) {
+	signed, err := m.signer.Sign(message)
+	if err != nil {
+		return
+	}
+	return m.next.Send(from, to, signed)
+}
+
+func splitMessage(message []byte) (header, body []byte) {
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		return message[:i+2], message[i+4:]
+	}
+	return message, nil
+}
+
+// parseHeaderFields возвращает поля заголовка вместе с продолжениями строк, без завершающего CRLF.
+func parseHeaderFields(header []byte) (fields []string) {
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) != 0 {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return
+}
+
+func lastHeaderField(fields []string, name string) (field string, ok bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		colon := strings.Index(fields[i], ":")
+		if colon > 0 && strings.EqualFold(strings.TrimSpace(fields[i][:colon]), name) {
+			return fields[i], true
+		}
+	}
+	return
+}
+
+func relaxedHeader(field string) string {
+	colon := strings.Index(field, ":")
+	name := strings.ToLower(strings.TrimSpace(field[:colon]))
+	value := strings.Replace(field[colon+1:], "\r\n", "", -1)
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+	return name + ":" + value
+}
+
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		line = strings.TrimRightFunc(line, isWSP)
+		lines[i] = strings.Join(splitKeepingEdges(line), " ")
+	}
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// splitKeepingEdges сжимает последовательности пробелов внутри строки, сохраняя ведущий пробел
+// (relaxed-канонизация тела его не удаляет).
+func splitKeepingEdges(line string) []string {
+	parts := strings.FieldsFunc(line, isWSP)
+	if len(line) != 0 && isWSP(rune(line[0])) {
+		parts = append([]string{""}, parts...)
+	}
+	return parts
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
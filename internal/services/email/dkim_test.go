@@ -0,0 +1,201 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const dkimTestMessage = "From: \"Pay Together\" <invite@pay-together.ru>\r\n" +
+	"To: <invitee@example.com>\r\n" +
+	"Subject: Invitation\r\n" +
+	"Date: Fri, 01 May 2020 12:00:00 +0000\r\n" +
+	"Message-ID: <1.test@pay-together.ru>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=\"utf-8\"\r\n" +
+	"\r\n" +
+	"Hello,  you are invited.\r\n" +
+	"\r\n" +
+	"See you\r\n"
+
+func TestDKIMRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPKCS8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		block     *pem.Block
+		public    crypto.PublicKey
+		algorithm string
+	}{
+		{"rsa", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, &rsaKey.PublicKey, "rsa-sha256"},
+		{"ed25519", &pem.Block{Type: "PRIVATE KEY", Bytes: edPKCS8}, edPublic, "ed25519-sha256"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			signer := newTestDKIMSigner(t, c.block)
+			signed, err := signer.Sign([]byte(dkimTestMessage))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tags, err := verifyDKIM(signed, c.public)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tags["a"] != c.algorithm || tags["c"] != "relaxed/relaxed" || tags["d"] != "pay-together.ru" || tags["s"] != "mail" {
+				t.Errorf("tags %v", tags)
+			}
+
+			// Relaxed-канонизация переживает перенос заголовков и изменения пробелов в пути.
+			rewrapped := bytes.Replace(signed, []byte("Subject: Invitation"), []byte("Subject:  \r\n\tInvitation "), 1)
+			rewrapped = bytes.Replace(rewrapped, []byte("Hello,  you"), []byte("Hello, \t you"), 1)
+			rewrapped = bytes.Replace(rewrapped, []byte("See you\r\n"), []byte("See you  \r\n\r\n\r\n"), 1)
+			if _, err = verifyDKIM(rewrapped, c.public); err != nil {
+				t.Errorf("relaxed changes: %v", err)
+			}
+
+			tampered := map[string][]byte{
+				"body":   bytes.Replace(signed, []byte("invited"), []byte("expelled"), 1),
+				"header": bytes.Replace(signed, []byte("Subject: Invitation"), []byte("Subject: Payment"), 1),
+			}
+			for name, message := range tampered {
+				if _, err = verifyDKIM(message, c.public); err == nil {
+					t.Errorf("tampered %s verified", name)
+				}
+			}
+		})
+	}
+}
+
+func newTestDKIMSigner(t *testing.T, block *pem.Block) DKIMSigner {
+	dir, err := ioutil.TempDir("", "dkim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "key.pem")
+	if err = ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewDKIMSigner(DKIMConfig{Domain: "pay-together.ru", Selector: "mail", PrivateKeyPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+var (
+	wspRun          = regexp.MustCompile(`[ \t]+`)
+	signatureBValue = regexp.MustCompile(`(;\s*b=)[^;]*`)
+)
+
+// verifyDKIM - независимая от подписывающего кода проверка по RFC 6376 (relaxed/relaxed) и RFC 8463.
+func verifyDKIM(message []byte, public crypto.PublicKey) (tags map[string]string, err error) {
+	end := bytes.Index(message, []byte("\r\n\r\n"))
+	if end < 0 {
+		return nil, errors.New("no body")
+	}
+	fields := testHeaderFields(string(message[:end]))
+	body := string(message[end+4:])
+	if len(fields) == 0 || !strings.HasPrefix(strings.ToLower(fields[0]), "dkim-signature:") {
+		return nil, errors.New("no signature")
+	}
+	signature := fields[0]
+
+	tags = make(map[string]string)
+	for _, tag := range strings.Split(signature[strings.Index(signature, ":")+1:], ";") {
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) == 2 {
+			tags[strings.TrimSpace(parts[0])] = strings.Join(strings.Fields(parts[1]), "")
+		}
+	}
+
+	bodyHash := sha256.Sum256([]byte(testRelaxedBody(body)))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return tags, errors.New("body hash mismatch")
+	}
+
+	hashed := ""
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if strings.EqualFold(strings.TrimSpace(fields[i][:strings.Index(fields[i], ":")]), name) {
+				hashed += testRelaxedHeader(fields[i]) + "\r\n"
+				break
+			}
+		}
+	}
+	hashed += testRelaxedHeader(signatureBValue.ReplaceAllString(signature, "${1}"))
+	digest := sha256.Sum256([]byte(hashed))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return
+	}
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest[:], sig) {
+			err = errors.New("ed25519 verification failed")
+		}
+	default:
+		err = fmt.Errorf("unsupported key %T", public)
+	}
+	return
+}
+
+func testHeaderFields(header string) (fields []string) {
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return
+}
+
+func testRelaxedHeader(field string) string {
+	colon := strings.Index(field, ":")
+	value := strings.ReplaceAll(field[colon+1:], "\r\n", "")
+	value = strings.Trim(wspRun.ReplaceAllString(value, " "), " ")
+	return strings.ToLower(strings.TrimRight(field[:colon], " \t")) + ":" + value
+}
+
+func testRelaxedBody(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(wspRun.ReplaceAllString(lines[i], " "), " ")
+	}
+	result := strings.TrimRight(strings.Join(lines, "\r\n"), "\r\n")
+	if result == "" {
+		return ""
+	}
+	return result + "\r\n"
+}
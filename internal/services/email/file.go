@@ -0,0 +1,46 @@
+package email
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileMailer складывает письма в каталог в формате maildir: файл пишется в tmp/ и переносится в new/.
+type fileMailer struct {
+	path string
+}
+
+func NewFileMailer(path string) (Mailer, error) {
+	for _, dir := range []string{"tmp", "new", "cur"} {
+		err := os.MkdirAll(filepath.Join(path, dir), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &fileMailer{
+		path: path,
+	}, nil
+}
+
+func (m *fileMailer) Send(from string, to []string, message []byte) (err error) {
+	suffix := make([]byte, 8)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%d.%s.eml", time.Now().UnixNano(), hex.EncodeToString(suffix))
+
+	tmpName := filepath.Join(m.path, "tmp", name)
+	err = ioutil.WriteFile(tmpName, message, 0644)
+	if err != nil {
+		return
+	}
+
+	return os.Rename(tmpName, filepath.Join(m.path, "new", name))
+}
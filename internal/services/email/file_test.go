@@ -0,0 +1,60 @@
+package email
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maildir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	mailer, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "cur")); err != nil {
+		t.Errorf("cur not created: %v", err)
+	}
+
+	for _, message := range []string{"first", "second"} {
+		if err = mailer.Send("group@host", []string{"user@host"}, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err := ioutil.ReadDir(filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d files left in tmp", len(pending))
+	}
+
+	delivered, err := ioutil.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 2 {
+		t.Fatalf("%d files in new, want 2", len(delivered))
+	}
+	got := make(map[string]bool)
+	for _, file := range delivered {
+		if filepath.Ext(file.Name()) != ".eml" {
+			t.Errorf("unexpected file %s", file.Name())
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, "new", file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[string(content)] = true
+	}
+	if !got["first"] || !got["second"] {
+		t.Errorf("delivered %v, want first and second", got)
+	}
+}
@@ -0,0 +1,89 @@
+package email
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	imageFetchTimeout = 5 * time.Second
+	imageMaxSize      = 512 * 1024
+	imageMaxRedirects = 3
+)
+
+var (
+	errImageHost      = errors.New("image host is not allowed")
+	errImageTooLarge  = errors.New("image is too large")
+	errImageRedirects = errors.New("too many image redirects")
+)
+
+// FetchImage скачивает картинку (например, аватар) для встраивания в письмо. Адрес картинки задаёт пользователь,
+// поэтому запросы, в том числе после перенаправлений, уходят только на allowedHost.
+func FetchImage(rawURL, contentID, allowedHost string) (attachment Attachment, err error) {
+	err = checkImageURL(rawURL, allowedHost)
+	if err != nil {
+		return
+	}
+
+	client := &http.Client{
+		Timeout: imageFetchTimeout,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= imageMaxRedirects {
+				return errImageRedirects
+			}
+			return checkImageURL(request.URL.String(), allowedHost)
+		},
+	}
+	response, err := client.Get(rawURL)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return attachment, errors.New("image fetch failed: " + response.Status)
+	}
+	if response.ContentLength > imageMaxSize {
+		return attachment, errImageTooLarge
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, imageMaxSize+1))
+	if err != nil {
+		return
+	}
+	if len(content) > imageMaxSize {
+		return attachment, errImageTooLarge
+	}
+
+	contentType := http.DetectContentType(content)
+	if !strings.HasPrefix(contentType, "image/") {
+		return attachment, errors.New("not an image: " + contentType)
+	}
+
+	return Attachment{
+		ContentType: contentType,
+		ContentID:   contentID,
+		Content:     content,
+	}, nil
+}
+
+// checkImageURL пропускает только http(s)-адреса на allowedHost (с портом, если он указан). Пустой allowedHost
+// запрещает скачивание картинок.
+func checkImageURL(rawURL, allowedHost string) (err error) {
+	imageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	if imageURL.Scheme != "https" && imageURL.Scheme != "http" {
+		return errImageHost
+	}
+	if allowedHost == "" || imageURL.User != nil || !strings.EqualFold(imageURL.Host, allowedHost) {
+		return errImageHost
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package email
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pngHeader достаточно, чтобы http.DetectContentType распознал картинку.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func TestFetchImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/avatar.png":
+			_, _ = w.Write(pngHeader)
+		case "/large.png":
+			_, _ = w.Write(append(pngHeader, bytes.Repeat([]byte{0}, imageMaxSize)...))
+		case "/away":
+			http.Redirect(w, r, "http://example.com/avatar.png", http.StatusFound)
+		case "/text":
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	image, err := FetchImage(server.URL+"/avatar.png", "avatar", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.ContentType != "image/png" || image.ContentID != "avatar" {
+		t.Errorf("image %q, %q", image.ContentType, image.ContentID)
+	}
+
+	cases := []struct {
+		name, url, host string
+	}{
+		{"other host", server.URL + "/avatar.png", "media.example.com"},
+		{"no host allowed", server.URL + "/avatar.png", ""},
+		{"credentials", "http://user@" + host + "/avatar.png", host},
+		{"scheme", "file:///etc/passwd", host},
+		{"redirect to other host", server.URL + "/away", host},
+		{"too large", server.URL + "/large.png", host},
+		{"not an image", server.URL + "/text", host},
+	}
+	for _, c := range cases {
+		if _, err = FetchImage(c.url, "avatar", c.host); err == nil {
+			t.Errorf("%s: fetched", c.name)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package email
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+)
+
+const (
+	BackendSMTP   = "smtp"
+	BackendFile   = "file"
+	BackendLog    = "log"
+	BackendMemory = "memory"
+)
+
+type Mailer interface {
+	Send(from string, to []string, message []byte) (err error)
+}
+
+type MailerConfig struct {
+	Backend string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPSecurity string
+	SMTPUsername string
+	SMTPPassword string
+
+	DropPath string
+}
+
+func NewMailer(config MailerConfig, log *zerolog.Logger) (Mailer, error) {
+	switch config.Backend {
+	case BackendSMTP:
+		return NewSMTPMailer(SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Security: config.SMTPSecurity,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+		})
+	case BackendFile:
+		return NewFileMailer(config.DropPath)
+	case BackendLog:
+		return NewLogMailer(log), nil
+	case BackendMemory:
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", config.Backend)
+	}
+}
@@ -0,0 +1,57 @@
+package email
+
+import (
+	"github.com/rs/zerolog"
+	"sync"
+)
+
+type SentMessage struct {
+	From    string
+	To      []string
+	Message []byte
+}
+
+// MemoryMailer запоминает отправленные письма вместо их отправки.
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []SentMessage
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(from string, to []string, message []byte) (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = append(m.messages, SentMessage{
+		From:    from,
+		To:      append([]string(nil), to...),
+		Message: append([]byte(nil), message...),
+	})
+	return
+}
+
+func (m *MemoryMailer) Messages() []SentMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]SentMessage(nil), m.messages...)
+}
+
+type logMailer struct {
+	log *zerolog.Logger
+}
+
+func NewLogMailer(log *zerolog.Logger) Mailer {
+	return &logMailer{
+		log: log,
+	}
+}
+
+func (m *logMailer) Send(from string, to []string, message []byte) (err error) {
+	m.log.Info().Str("msg", "mailer: message dropped to log").Str("from", from).Strs("to", to).
+		Bytes("message", message).Send()
+	return
+}
@@ -0,0 +1,251 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const base64LineLength = 76
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	// ContentID задаётся для встроенных картинок, на которые html ссылается как cid:<ContentID>.
+	ContentID string
+	Content   []byte
+}
+
+type Message struct {
+	From    mail.Address
+	To      []mail.Address
+	Subject string
+
+	Text []byte
+	HTML []byte
+
+	Inline      []Attachment
+	Attachments []Attachment
+
+	// ListUnsubscribe - адреса (mailto: или https:) для заголовка List-Unsubscribe.
+	ListUnsubscribe []string
+
+	Date      time.Time
+	MessageID string
+}
+
+// Bytes собирает письмо: multipart/mixed (если есть вложения) -> multipart/related (если есть
+// встроенные картинки) -> multipart/alternative с текстовой и html частями.
+func (m Message) Bytes() (result []byte, err error) {
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	if m.MessageID == "" {
+		m.MessageID, err = newMessageID(m.From.Address)
+		if err != nil {
+			return
+		}
+	}
+
+	buffer := bytes.NewBufferString("")
+	writeHeader(buffer, "From", m.From.String())
+	writeHeader(buffer, "To", joinAddresses(m.To))
+	writeHeader(buffer, "Subject", mime.BEncoding.Encode("utf-8", strings.Join(strings.Fields(m.Subject), " ")))
+	writeHeader(buffer, "Date", m.Date.Format(time.RFC1123Z))
+	writeHeader(buffer, "Message-ID", m.MessageID)
+	if len(m.ListUnsubscribe) != 0 {
+		writeHeader(buffer, "List-Unsubscribe", "<"+strings.Join(m.ListUnsubscribe, ">, <")+">")
+		for _, address := range m.ListUnsubscribe {
+			if strings.HasPrefix(address, "https:") {
+				writeHeader(buffer, "List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+				break
+			}
+		}
+	}
+	writeHeader(buffer, "MIME-Version", "1.0")
+
+	err = m.writeBody(buffer)
+	if err != nil {
+		return
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (m Message) writeBody(buffer *bytes.Buffer) (err error) {
+	if len(m.Attachments) == 0 {
+		return m.writeRelated(buffer, nil)
+	}
+
+	mixed := multipart.NewWriter(buffer)
+	writeHeader(buffer, "Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buffer.WriteString("\r\n")
+
+	err = m.writeRelated(nil, mixed)
+	if err != nil {
+		return
+	}
+	for _, attachment := range m.Attachments {
+		err = writeAttachment(mixed, attachment, "attachment")
+		if err != nil {
+			return
+		}
+	}
+
+	return mixed.Close()
+}
+
+// writeRelated пишет либо прямо в тело письма (parent == nil), либо очередной частью parent.
+func (m Message) writeRelated(buffer *bytes.Buffer, parent *multipart.Writer) (err error) {
+	if len(m.Inline) == 0 {
+		return m.writeAlternative(buffer, parent)
+	}
+
+	related, err := nestedWriter(buffer, parent, "multipart/related")
+	if err != nil {
+		return
+	}
+
+	err = m.writeAlternative(nil, related)
+	if err != nil {
+		return
+	}
+	for _, attachment := range m.Inline {
+		err = writeAttachment(related, attachment, "inline")
+		if err != nil {
+			return
+		}
+	}
+
+	return related.Close()
+}
+
+func (m Message) writeAlternative(buffer *bytes.Buffer, parent *multipart.Writer) (err error) {
+	alternative, err := nestedWriter(buffer, parent, "multipart/alternative")
+	if err != nil {
+		return
+	}
+
+	if m.Text != nil {
+		err = writePart(alternative, textproto.MIMEHeader{
+			"Content-Type": {`text/plain; charset="utf-8"`},
+		}, m.Text)
+		if err != nil {
+			return
+		}
+	}
+	if m.HTML != nil {
+		err = writePart(alternative, textproto.MIMEHeader{
+			"Content-Type": {`text/html; charset="utf-8"`},
+		}, m.HTML)
+		if err != nil {
+			return
+		}
+	}
+
+	return alternative.Close()
+}
+
+// nestedWriter создаёт multipart-писатель: в корне письма он пишет заголовок Content-Type в buffer,
+// внутри другого multipart - создаёт для себя отдельную часть.
+func nestedWriter(buffer *bytes.Buffer, parent *multipart.Writer, mediaType string) (writer *multipart.Writer, err error) {
+	if parent == nil {
+		writer = multipart.NewWriter(buffer)
+		writeHeader(buffer, "Content-Type", mime.FormatMediaType(mediaType, map[string]string{"boundary": writer.Boundary()}))
+		buffer.WriteString("\r\n")
+		return writer, nil
+	}
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	out, err := parent.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType(mediaType, map[string]string{"boundary": boundary})},
+	})
+	if err != nil {
+		return
+	}
+	writer = multipart.NewWriter(out)
+	err = writer.SetBoundary(boundary)
+	return
+}
+
+func writeAttachment(writer *multipart.Writer, attachment Attachment, disposition string) (err error) {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type": {contentType},
+	}
+	if attachment.Filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	} else {
+		header.Set("Content-Disposition", disposition)
+	}
+	if attachment.ContentID != "" {
+		header.Set("Content-ID", "<"+attachment.ContentID+">")
+	}
+
+	return writePart(writer, header, attachment.Content)
+}
+
+func writePart(writer *multipart.Writer, header textproto.MIMEHeader, content []byte) (err error) {
+	header.Set("Content-Transfer-Encoding", "base64")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return
+	}
+	_, err = part.Write(wrapBase64(content))
+	return
+}
+
+func wrapBase64(content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	buffer := bytes.NewBufferString("")
+	for len(encoded) > base64LineLength {
+		buffer.WriteString(encoded[:base64LineLength])
+		buffer.WriteString("\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	buffer.WriteString(encoded)
+	buffer.WriteString("\r\n")
+	return buffer.Bytes()
+}
+
+func writeHeader(buffer *bytes.Buffer, key, value string) {
+	buffer.WriteString(key)
+	buffer.WriteString(": ")
+	buffer.WriteString(value)
+	buffer.WriteString("\r\n")
+}
+
+func joinAddresses(addresses []mail.Address) string {
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		result = append(result, address.String())
+	}
+	return strings.Join(result, ", ")
+}
+
+func newMessageID(from string) (messageID string, err error) {
+	random := make([]byte, 16)
+	_, err = rand.Read(random)
+	if err != nil {
+		return
+	}
+
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain), nil
+}
@@ -0,0 +1,74 @@
+package email
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// boundaryPattern находит случайные границы multipart, чтобы заменить их в результате на постоянные.
+var boundaryPattern = regexp.MustCompile(`boundary=([0-9a-f]+)`)
+
+func TestMessageGolden(t *testing.T) {
+	base := Message{
+		From:            mail.Address{Name: "Pay Together", Address: "invite@pay-together.ru"},
+		To:              []mail.Address{{Address: "invitee@example.com"}},
+		Subject:         "Приглашение в группу",
+		Text:            []byte("Вас пригласили в группу"),
+		HTML:            []byte("<p>Вас пригласили в группу</p>"),
+		ListUnsubscribe: []string{"mailto:unsubscribe@pay-together.ru", "https://pay-together.ru/unsubscribe"},
+		Date:            time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC),
+		MessageID:       "<1.test@pay-together.ru>",
+	}
+
+	withInline := base
+	withInline.Inline = []Attachment{{ContentType: "image/png", ContentID: "avatar@paytogether", Content: pngHeader}}
+
+	withAttachment := withInline
+	withAttachment.Attachments = []Attachment{{Filename: "rules.txt", ContentType: "text/plain", Content: []byte("rules")}}
+
+	cases := []struct {
+		name    string
+		message Message
+	}{
+		{"alternative", base},
+		{"related", withInline},
+		{"mixed", withAttachment},
+	}
+	for _, c := range cases {
+		result, err := c.message.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = normalizeBoundaries(result)
+
+		golden := filepath.Join("testdata", c.name+".eml")
+		if *update {
+			if err = ioutil.WriteFile(golden, result, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(result, expected) {
+			t.Errorf("%s: message differs from %s:\n%s", c.name, golden, result)
+		}
+	}
+}
+
+func normalizeBoundaries(message []byte) []byte {
+	for i, match := range boundaryPattern.FindAllSubmatch(message, -1) {
+		message = bytes.ReplaceAll(message, match[1], []byte("boundary"+string(rune('0'+i))))
+	}
+	return message
+}
@@ -0,0 +1,105 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+const (
+	SecurityNone     = "none"
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Security string
+	Username string
+	Password string
+}
+
+type smtpMailer struct {
+	config SMTPConfig
+}
+
+func NewSMTPMailer(config SMTPConfig) (Mailer, error) {
+	switch config.Security {
+	case SecurityNone, SecurityStartTLS, SecurityTLS:
+	default:
+		return nil, fmt.Errorf("unknown smtp security mode %q", config.Security)
+	}
+
+	return &smtpMailer{
+		config: config,
+	}, nil
+}
+
+func (m *smtpMailer) Send(from string, to []string, message []byte) (err error) {
+	client, err := m.dial()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if m.config.Security == SecurityStartTLS {
+		err = client.StartTLS(&tls.Config{ServerName: m.config.Host})
+		if err != nil {
+			return
+		}
+	}
+
+	if m.config.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host))
+		if err != nil {
+			return
+		}
+	}
+
+	err = client.Mail(from)
+	if err != nil {
+		return
+	}
+	for _, recipient := range to {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return
+	}
+	_, err = writer.Write(message)
+	if err != nil {
+		return
+	}
+	err = writer.Close()
+	if err != nil {
+		return
+	}
+
+	return client.Quit()
+}
+
+func (m *smtpMailer) dial() (client *smtp.Client, err error) {
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+
+	if m.config.Security != SecurityTLS {
+		return smtp.Dial(address)
+	}
+
+	conn, err := tls.Dial("tcp", address, &tls.Config{ServerName: m.config.Host})
+	if err != nil {
+		return
+	}
+	client, err = smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+	}
+	return
+}
@@ -0,0 +1,302 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+type TemplateRegistry interface {
+	Execute(locale, name string, vars interface{}) (result []byte, err error)
+	Locales() (locales []string)
+	Reload() (err error)
+	Watch(interval time.Duration, onReload func(err error))
+	Shutdown()
+}
+
+// templateRegistry держит разобранные шаблоны из каталогов basePath/<locale>/ и из самого basePath.
+// Если шаблона нет для запрошенной локали, пробуется основной язык (en-US -> en), затем локаль
+// по умолчанию и, наконец, сам basePath.
+type templateRegistry struct {
+	basePath      string
+	defaultLocale string
+	validate      func(templates TemplateRegistry) error
+
+	mutex       sync.RWMutex
+	templates   map[string]map[string]*template.Template
+	fingerprint string
+
+	watching bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTemplateRegistry загружает шаблоны и проверяет их функцией validate (может быть nil).
+// Та же проверка выполняется при каждой перезагрузке до того, как новые шаблоны начнут использоваться.
+func NewTemplateRegistry(basePath, defaultLocale string, validate func(templates TemplateRegistry) error) (TemplateRegistry, error) {
+	registry := &templateRegistry{
+		basePath:      basePath,
+		defaultLocale: NormalizeLocale(defaultLocale),
+		validate:      validate,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	err := registry.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r *templateRegistry) Execute(locale, name string, vars interface{}) (result []byte, err error) {
+	tmpl, err := r.lookup(locale, name)
+	if err != nil {
+		return
+	}
+
+	writer := bytes.NewBufferString("")
+	err = tmpl.Execute(writer, vars)
+	if err != nil {
+		return
+	}
+
+	return writer.Bytes(), nil
+}
+
+// Reload разбирает все шаблоны заново. При ошибке продолжают использоваться ранее загруженные.
+func (r *templateRegistry) Reload() (err error) {
+	templates, fingerprint, err := r.load()
+	if err != nil {
+		return
+	}
+
+	if r.validate != nil {
+		candidate := &templateRegistry{
+			defaultLocale: r.defaultLocale,
+			templates:     templates,
+		}
+		err = r.validate(candidate)
+		if err != nil {
+			return
+		}
+	}
+
+	r.mutex.Lock()
+	r.templates = templates
+	r.fingerprint = fingerprint
+	r.mutex.Unlock()
+	return
+}
+
+// Watch раз в interval проверяет, менялись ли файлы шаблонов, и перечитывает их при изменении.
+func (r *templateRegistry) Watch(interval time.Duration, onReload func(err error)) {
+	r.mutex.Lock()
+	r.watching = true
+	r.mutex.Unlock()
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			fingerprint, err := r.scan()
+			r.mutex.RLock()
+			changed := err != nil || fingerprint != r.fingerprint
+			r.mutex.RUnlock()
+			if !changed {
+				continue
+			}
+			if err == nil {
+				err = r.Reload()
+			}
+			onReload(err)
+			if err != nil {
+				// Не повторяем ту же ошибку на каждом тике, пока файлы снова не изменятся.
+				r.mutex.Lock()
+				r.fingerprint = fingerprint
+				r.mutex.Unlock()
+			}
+		}
+	}
+}
+
+func (r *templateRegistry) Shutdown() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+
+	r.mutex.RLock()
+	watching := r.watching
+	r.mutex.RUnlock()
+	if watching {
+		<-r.done
+	}
+}
+
+func (r *templateRegistry) Locales() (locales []string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for locale := range r.templates {
+		if locale != "" {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+	return
+}
+
+func (r *templateRegistry) lookup(locale, name string) (tmpl *template.Template, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, candidate := range append(r.fallbacks(locale), "") {
+		if tmpl, ok := r.templates[candidate][name]; ok {
+			return tmpl, nil
+		}
+	}
+	return nil, fmt.Errorf("template %q not found", name)
+}
+
+func (r *templateRegistry) load() (templates map[string]map[string]*template.Template, fingerprint string, err error) {
+	files, err := r.files()
+	if err != nil {
+		return
+	}
+
+	templates = make(map[string]map[string]*template.Template)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			return nil, "", err
+		}
+		tmpl, err := template.New(file.name).Parse(string(content))
+		if err != nil {
+			return nil, "", err
+		}
+		if templates[file.locale] == nil {
+			templates[file.locale] = make(map[string]*template.Template)
+		}
+		templates[file.locale][file.name] = tmpl
+	}
+
+	return templates, fingerprintOf(files), nil
+}
+
+func (r *templateRegistry) scan() (fingerprint string, err error) {
+	files, err := r.files()
+	if err != nil {
+		return
+	}
+	return fingerprintOf(files), nil
+}
+
+type templateFile struct {
+	locale  string
+	name    string
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files перечисляет шаблоны в basePath и в его подкаталогах первого уровня (по одному на локаль).
+func (r *templateRegistry) files() (files []templateFile, err error) {
+	entries, err := ioutil.ReadDir(r.basePath)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = appendTemplateFile(files, "", r.basePath, entry)
+			continue
+		}
+
+		locale := NormalizeLocale(entry.Name())
+		if locale == "" {
+			continue
+		}
+		dir := filepath.Join(r.basePath, entry.Name())
+		localeEntries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, localeEntry := range localeEntries {
+			if !localeEntry.IsDir() {
+				files = appendTemplateFile(files, locale, dir, localeEntry)
+			}
+		}
+	}
+	return
+}
+
+func appendTemplateFile(files []templateFile, locale, dir string, info os.FileInfo) []templateFile {
+	if strings.HasPrefix(info.Name(), ".") {
+		return files
+	}
+	return append(files, templateFile{
+		locale:  locale,
+		name:    info.Name(),
+		path:    filepath.Join(dir, info.Name()),
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	})
+}
+
+func fingerprintOf(files []templateFile) string {
+	builder := strings.Builder{}
+	for _, file := range files {
+		fmt.Fprintf(&builder, "%s|%d|%d;", file.path, file.size, file.modTime.UnixNano())
+	}
+	return builder.String()
+}
+
+func (r *templateRegistry) fallbacks(locale string) (locales []string) {
+	locale = NormalizeLocale(locale)
+	if locale != "" {
+		locales = append(locales, locale)
+		if i := strings.Index(locale, "-"); i > 0 {
+			locales = append(locales, locale[:i])
+		}
+	}
+	if r.defaultLocale != "" {
+		locales = append(locales, r.defaultLocale)
+	}
+	return
+}
+
+// NormalizeLocale приводит теги вида "en_US" или "EN-us" к виду "en-us" и отбрасывает всё,
+// что не похоже на языковой тег.
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	locale = strings.Replace(locale, "_", "-", -1)
+	for _, r := range locale {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return ""
+		}
+	}
+	return locale
+}
+
+// ParseAcceptLanguage возвращает первый язык из заголовка Accept-Language.
+func ParseAcceptLanguage(header string) string {
+	first := strings.SplitN(header, ",", 2)[0]
+	first = strings.SplitN(first, ";", 2)[0]
+	if first == "*" {
+		return ""
+	}
+	return NormalizeLocale(first)
+}
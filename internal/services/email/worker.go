@@ -0,0 +1,131 @@
+package email
+
+import (
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	"github.com/rs/zerolog"
+	"sync"
+	"time"
+)
+
+const (
+	batchSize  = 10
+	sendLease  = 5 * time.Minute
+	maxBackoff = 6 * time.Hour
+)
+
+type outboxStorage interface {
+	ClaimMessages(limit int, lease time.Duration) (messages []models.OutboxMessage, err error)
+	MarkSent(messageID int) (err error)
+	MarkFailed(messageID int, lastError string, nextAttemptAt time.Time) (err error)
+	MarkDead(messageID int, lastError string) (err error)
+}
+
+type WorkerConfig struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+}
+
+// Worker отправляет письма из таблицы email_outbox. Запускается в единственном экземпляре на процесс.
+type Worker interface {
+	Run()
+	Shutdown()
+}
+
+type worker struct {
+	outboxStorage outboxStorage
+	mailer        Mailer
+	config        WorkerConfig
+	log           *zerolog.Logger
+	stop          chan struct{}
+	done          chan struct{}
+	stopOnce      sync.Once
+}
+
+func NewWorker(outboxStorage outboxStorage, mailer Mailer, config WorkerConfig, log *zerolog.Logger) Worker {
+	return &worker{
+		outboxStorage: outboxStorage,
+		mailer:        mailer,
+		config:        config,
+		log:           log,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func (w *worker) Run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processBatch()
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown дожидается окончания отправки текущей пачки писем.
+func (w *worker) Shutdown() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *worker) processBatch() {
+	messages, err := w.outboxStorage.ClaimMessages(batchSize, sendLease)
+	if err != nil {
+		w.log.Error().Str("msg", "mailer: cannot claim messages").Err(err).Send()
+		return
+	}
+
+	for _, message := range messages {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		w.process(message)
+	}
+}
+
+func (w *worker) process(message models.OutboxMessage) {
+	err := w.mailer.Send(message.From, []string{message.To}, message.Message)
+	if err == nil {
+		err = w.outboxStorage.MarkSent(message.ID)
+		if err != nil {
+			w.log.Error().Str("msg", "mailer: cannot mark message sent").Int("id", message.ID).Err(err).Send()
+			return
+		}
+		w.log.Info().Str("msg", "mailer: message sent").Int("id", message.ID).Str("to", message.To).Send()
+		return
+	}
+
+	w.log.Warn().Str("msg", "mailer: cannot send message").Int("id", message.ID).Str("to", message.To).
+		Int("attempt", message.Attempts).Err(err).Send()
+
+	if message.Attempts >= w.config.MaxAttempts {
+		err = w.outboxStorage.MarkDead(message.ID, err.Error())
+	} else {
+		err = w.outboxStorage.MarkFailed(message.ID, err.Error(), time.Now().Add(w.backoff(message.Attempts)))
+	}
+	if err != nil {
+		w.log.Error().Str("msg", "mailer: cannot update message status").Int("id", message.ID).Err(err).Send()
+	}
+}
+
+func (w *worker) backoff(attempts int) time.Duration {
+	delay := w.config.PollInterval
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
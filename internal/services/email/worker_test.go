@@ -0,0 +1,185 @@
+package email
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	"github.com/rs/zerolog"
+)
+
+var errMailerDown = errors.New("mailer is down")
+
+type failingMailer struct{}
+
+func (failingMailer) Send(from string, to []string, message []byte) error {
+	return errMailerDown
+}
+
+type failedMark struct {
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+// fakeOutbox отдаёт очередь один раз, увеличивая число попыток, как это делает ClaimMessages в базе.
+type fakeOutbox struct {
+	mutex  sync.Mutex
+	queue  []models.OutboxMessage
+	sent   []int
+	failed map[int]failedMark
+	dead   map[int]string
+}
+
+func newFakeOutbox(messages ...models.OutboxMessage) *fakeOutbox {
+	return &fakeOutbox{
+		queue:  messages,
+		failed: make(map[int]failedMark),
+		dead:   make(map[int]string),
+	}
+}
+
+func (o *fakeOutbox) ClaimMessages(limit int, lease time.Duration) (messages []models.OutboxMessage, err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for len(o.queue) > 0 && len(messages) < limit {
+		message := o.queue[0]
+		message.Attempts++
+		messages = append(messages, message)
+		o.queue = o.queue[1:]
+	}
+	return
+}
+
+func (o *fakeOutbox) MarkSent(messageID int) (err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.sent = append(o.sent, messageID)
+	return
+}
+
+func (o *fakeOutbox) MarkFailed(messageID int, lastError string, nextAttemptAt time.Time) (err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.failed[messageID] = failedMark{lastError: lastError, nextAttemptAt: nextAttemptAt}
+	return
+}
+
+func (o *fakeOutbox) MarkDead(messageID int, lastError string) (err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.dead[messageID] = lastError
+	return
+}
+
+func (o *fakeOutbox) sentCount() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return len(o.sent)
+}
+
+func newTestWorker(outbox outboxStorage, mailer Mailer, config WorkerConfig) *worker {
+	log := zerolog.New(ioutil.Discard)
+	return NewWorker(outbox, mailer, config, &log).(*worker)
+}
+
+func TestWorkerSendsAndShutsDown(t *testing.T) {
+	outbox := newFakeOutbox(
+		models.OutboxMessage{ID: 1, From: "group@host", To: "first@host", Message: []byte("first")},
+		models.OutboxMessage{ID: 2, From: "group@host", To: "second@host", Message: []byte("second")},
+	)
+	mailer := NewMemoryMailer()
+	w := newTestWorker(outbox, mailer, WorkerConfig{PollInterval: 10 * time.Millisecond, MaxAttempts: 3})
+
+	go w.Run()
+	deadline := time.Now().Add(time.Second)
+	for outbox.sentCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Shutdown()
+		w.Shutdown()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+
+	if len(outbox.sent) != 2 || outbox.sent[0] != 1 || outbox.sent[1] != 2 {
+		t.Errorf("sent %v, want [1 2]", outbox.sent)
+	}
+	messages := mailer.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("mailer got %d messages, want 2", len(messages))
+	}
+	if messages[0].From != "group@host" || len(messages[0].To) != 1 || messages[0].To[0] != "first@host" ||
+		string(messages[0].Message) != "first" {
+		t.Errorf("unexpected message %+v", messages[0])
+	}
+}
+
+func TestWorkerRetriesFailedMessages(t *testing.T) {
+	const interval = time.Minute
+	outbox := newFakeOutbox()
+	w := newTestWorker(outbox, failingMailer{}, WorkerConfig{PollInterval: interval, MaxAttempts: 3})
+
+	before := time.Now()
+	w.process(models.OutboxMessage{ID: 1, To: "retry@host", Attempts: 2})
+	w.process(models.OutboxMessage{ID: 2, To: "dead@host", Attempts: 3})
+
+	failed, ok := outbox.failed[1]
+	if !ok {
+		t.Fatal("message 1 not marked failed")
+	}
+	if failed.lastError != errMailerDown.Error() {
+		t.Errorf("last error %q, want %q", failed.lastError, errMailerDown.Error())
+	}
+	if delay := failed.nextAttemptAt.Sub(before); delay < 2*interval || delay > 2*interval+time.Second {
+		t.Errorf("next attempt in %v, want %v", delay, 2*interval)
+	}
+	if _, ok = outbox.dead[1]; ok {
+		t.Error("message 1 marked dead")
+	}
+
+	if outbox.dead[2] != errMailerDown.Error() {
+		t.Errorf("message 2 dead with %q, want %q", outbox.dead[2], errMailerDown.Error())
+	}
+	if _, ok = outbox.failed[2]; ok {
+		t.Error("message 2 rescheduled after the last attempt")
+	}
+	if len(outbox.sent) != 0 {
+		t.Errorf("sent %v, want none", outbox.sent)
+	}
+}
+
+func TestWorkerBackoff(t *testing.T) {
+	w := newTestWorker(newFakeOutbox(), failingMailer{}, WorkerConfig{PollInterval: time.Minute, MaxAttempts: 20})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 9, want: 256 * time.Minute},
+		{attempts: 10, want: maxBackoff},
+		{attempts: 15, want: maxBackoff},
+	}
+	for _, test := range tests {
+		if got := w.backoff(test.attempts); got != test.want {
+			t.Errorf("backoff(%d) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
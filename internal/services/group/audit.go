@@ -0,0 +1,125 @@
+package group
+
+import (
+	"encoding/json"
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+	"strconv"
+	"time"
+)
+
+const (
+	auditTargetGroup = "group"
+	auditTargetUser  = "user"
+	auditTargetLink  = "link"
+	auditTargetRole  = "role"
+	// auditTargetInvitation - приглашение пользователя; target_id - ID приглашения.
+	auditTargetInvitation = "invitation"
+)
+
+// auditGroup - настройки группы, которые попадают в журнал аудита.
+type auditGroup struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	URL          string `json:"URL"`
+	AvatarURL    string `json:"avatarURL"`
+	JoinApproval bool   `json:"joinApproval"`
+	JoinRoleID   int    `json:"joinRoleID"`
+	VisibilityID int    `json:"visibility"`
+	StatusID     int    `json:"status"`
+}
+
+func newAuditGroup(group models2.Group) auditGroup {
+	return auditGroup{
+		Title:        group.Title,
+		Description:  group.Description,
+		URL:          group.URL,
+		AvatarURL:    group.AvatarURL,
+		JoinApproval: group.JoinApproval,
+		JoinRoleID:   group.JoinRoleID,
+		VisibilityID: group.VisibilityID,
+		StatusID:     group.StatusID,
+	}
+}
+
+// auditMember - роль участника до и после изменения.
+type auditMember struct {
+	RoleID   int    `json:"roleID"`
+	RoleName string `json:"roleName,omitempty"`
+}
+
+// auditInvitation - приглашение пользователя в группу.
+type auditInvitation struct {
+	UserID   int `json:"userID"`
+	RoleID   int `json:"roleID"`
+	StatusID int `json:"status"`
+}
+
+func newAuditInvitation(invitation models2.Invitation) auditInvitation {
+	return auditInvitation{
+		UserID:   invitation.UserID,
+		RoleID:   invitation.RoleID,
+		StatusID: invitation.StatusID,
+	}
+}
+
+// auditOwner - создатель группы до и после передачи.
+type auditOwner struct {
+	CreatorID int `json:"creatorID"`
+}
+
+// auditLink - приглашение по ссылке. Сам токен хранится в target_id.
+type auditLink struct {
+	RoleID    int    `json:"roleID"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	MaxUses   int    `json:"maxUses"`
+	Uses      int    `json:"uses"`
+}
+
+func newAuditLink(link models2.GroupInviteLink) auditLink {
+	value := auditLink{
+		RoleID:  link.RoleID,
+		MaxUses: link.MaxUses,
+		Uses:    link.Uses,
+	}
+	if link.ExpiresAt != nil {
+		value.ExpiresAt = link.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return value
+}
+
+// audit пишет запись в журнал. Вызывается внутри inTx, чтобы запись фиксировалась вместе с изменением.
+// before и after - значения, которые сериализуются в JSON; nil означает отсутствие состояния.
+func (s *service) audit(entry models2.AuditEntry, before, after interface{}) (err error) {
+	entry.Before, err = auditValue(before)
+	if err != nil {
+		return
+	}
+	entry.After, err = auditValue(after)
+	if err != nil {
+		return
+	}
+	return s.groupStorage.InsertAuditEntry(entry)
+}
+
+func auditValue(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
+
+func auditID(id int) string {
+	return strconv.Itoa(id)
+}
+
+func (s *service) GetAuditLog(request models.AuditListRequest) (entries []models2.AuditEntry, page models.Page, err error) {
+	normalizeListParams(&request.ListParams, models.SortByCreated)
+	request.SortBy = models.SortByCreated
+	request.Desc = true
+	request.RoleID = 0
+
+	entries, page, err = s.groupStorage.SelectAuditEntries(request)
+	err = s.listError(err)
+	return
+}
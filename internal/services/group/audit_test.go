@@ -0,0 +1,130 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+)
+
+func TestMembershipFlowsAreAudited(t *testing.T) {
+	f := newServiceFixture(t)
+
+	invited, err := f.service.Invite(models.InviteUserRequest{
+		CreatorID: testAdminID,
+		RequestID: "invite",
+		Group:     f.groupID,
+		UserID:    []int{42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitations, err := f.storage.SelectInvitationsByUserID(invited.UserID[0])
+	if err != nil || len(invitations) != 1 {
+		t.Fatalf("invitations %+v, %v", invitations, err)
+	}
+	_, err = f.service.AcceptInvitation(models.InvitationActionRequest{ActorID: 42, RequestID: "accept",
+		InvitationID: invitations[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joinRequest, err := f.storage.InsertJoinRequest(f.groupID, 43)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.service.ApproveJoinRequest(models.JoinRequestDecisionRequest{ActorID: testAdminID, TraceID: "approve",
+		Group: f.groupID, RequestID: joinRequest.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer, err := f.service.ProposeTransfer(models.ProposeTransferRequest{ActorID: testCreatorID, Group: f.groupID,
+		UserID: testAdminID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.service.AcceptTransfer(models.TransferActionRequest{ActorID: testAdminID, RequestID: "transfer",
+		TransferID: transfer.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Письмо с одноразовой ссылкой: повторная отправка заменяет ссылку, переход по ней принимает приглашение.
+	invited, err = f.service.Invite(models.InviteUserRequest{
+		CreatorID: testAdminID,
+		RequestID: "invite by letter",
+		Group:     f.groupID,
+		UserID:    []int{44},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitations, err = f.storage.SelectInvitationsByUserID(44)
+	if err != nil || len(invitations) != 1 {
+		t.Fatalf("invitations %+v, %v", invitations, err)
+	}
+	for _, requestID := range []string{"letter", "second letter"} {
+		_, err = f.service.ResendInvitation(models.ResendInvitationRequest{ActorID: testAdminID, RequestID: requestID,
+			Group: f.groupID, InvitationID: invitations[0].ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = f.service.ResolveGroup(models.ResolveInviteLinkRequest{RequestID: "follow letter",
+		Link: f.service.getLinkFromHash("token2"), UserID: 44})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _, err := f.storage.SelectAuditEntries(models.AuditListRequest{
+		Group:      f.groupID,
+		ListParams: models.ListParams{Limit: 20, SortBy: models.SortByCreated},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		action, requestID string
+		actorID           int
+	}{
+		{models2.AuditInvitationCreate, "invite", testAdminID},
+		{models2.AuditInvitationAccept, "accept", 42},
+		{models2.AuditMemberJoin, "approve", testAdminID},
+		{models2.AuditGroupTransfer, "transfer", testAdminID},
+		{models2.AuditInvitationCreate, "invite by letter", testAdminID},
+		{models2.AuditLinkCreate, "letter", testAdminID},
+		{models2.AuditLinkCreate, "second letter", testAdminID},
+		{models2.AuditLinkRemove, "second letter", testAdminID},
+		{models2.AuditInvitationAccept, "follow letter", 44},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(expected), entries)
+	}
+	for i, e := range expected {
+		entry := entries[i]
+		if entry.Action != e.action || entry.RequestID != e.requestID || entry.ActorID != e.actorID {
+			t.Errorf("entry %d: got %s by %d in %q, want %s by %d in %q", i, entry.Action, entry.ActorID, entry.RequestID,
+				e.action, e.actorID, e.requestID)
+		}
+	}
+}
+
+func TestAcceptTransferRollsBackWithoutAudit(t *testing.T) {
+	f := newServiceFixture(t)
+	transfer, err := f.service.ProposeTransfer(models.ProposeTransferRequest{ActorID: testCreatorID, Group: f.groupID,
+		UserID: testAdminID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.service.groupStorage = newFailingStorage(f.storage, "InsertAuditEntry", 0)
+
+	_, err = f.service.AcceptTransfer(models.TransferActionRequest{ActorID: testAdminID, TransferID: transfer.ID})
+	if err != errStepFailed {
+		t.Fatalf("got %v, want %v", err, errStepFailed)
+	}
+	role, err := f.storage.SelectGroupRole(f.groupID, testCreatorID)
+	if err != nil || role.RoleID != int(models2.RoleCreator) {
+		t.Errorf("creator role %+v, %v", role, err)
+	}
+}
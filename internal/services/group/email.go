@@ -1,127 +1,114 @@
 package group
 
 import (
-	"bytes"
-	"encoding/base64"
-	"fmt"
 	"github.com/Solar-2020/Group-Backend/internal"
-	"net/smtp"
-	"sync"
-	"text/template"
+	"github.com/Solar-2020/Group-Backend/internal/services/email"
+	"net/mail"
+	"strings"
 )
 
-const (
-	maxFails = 2
-)
+const avatarContentID = "avatar@paytogether"
 
-var (
-	queue Queue
-	queueInit sync.Once
+const (
+	inviteTemplateHTML    = "invite.html"
+	inviteTemplateText    = "invite.txt"
+	inviteTemplateSubject = "subject.txt"
+	// inviteTemplateMessage - не файл, а письмо целиком, собранное из трёх шаблонов выше.
+	inviteTemplateMessage = "invite.eml"
 )
 
 type TemplateUser struct {
-	FullName 	string
-	Email 		string
-	Avatar 		string
+	FullName string
+	Email    string
+	Avatar   string
 }
 
-func sendInviteMessage(to string, adminName, adminSirname, adminEmail, adminAvatar string, group, link string) (err error) {
-	from := internal.Config.InviteLetterSender
-	host := internal.Config.InviteLetterHost
+type inviteTemplateVars struct {
+	Admin      TemplateUser
+	GroupName  string
+	InviteLink string
+}
 
-	tmplHTML, err := template.ParseFiles(internal.Config.InviteLetterBasePath + "/invite.html")
-	if err != nil {
-		return
+func sampleInviteTemplateVars() inviteTemplateVars {
+	return inviteTemplateVars{
+		Admin: TemplateUser{
+			FullName: "Иван Иванов",
+			Email:    "admin@example.com",
+		},
+		GroupName:  "Пример группы",
+		InviteLink: internal.Config.InviteLinkPrefix + "/example",
 	}
-	tmplText, err := template.ParseFiles(internal.Config.InviteLetterBasePath + "/invite.txt")
-	if err != nil {
-		return
+}
+
+// ValidateInviteTemplates собирает пробное приглашение на каждой локали, чтобы ошибки в шаблонах
+// обнаруживались при загрузке, а не при отправке первого письма.
+func ValidateInviteTemplates(templates email.TemplateRegistry) (err error) {
+	for _, locale := range append(templates.Locales(), "") {
+		_, err = renderInviteMessage(templates, locale, "invitee@example.com", sampleInviteTemplateVars(), nil)
+		if err != nil {
+			return
+		}
 	}
+	return
+}
 
-	vars := struct{
-		Admin TemplateUser
-		GroupName string
-		InviteLink string
-	}{
-		TemplateUser{
+func composeInviteMessage(templates templateRegistry, locale string, to string, adminName, adminSirname, adminEmail, adminAvatar string,
+	group, link string) (message []byte, err error) {
+	vars := inviteTemplateVars{
+		Admin: TemplateUser{
 			FullName: adminName + " " + adminSirname,
 			Email:    adminEmail,
-			Avatar:   adminAvatar,
 		},
-		group,
-		link,
+		GroupName:  group,
+		InviteLink: link,
 	}
 
-	htmlWriter := bytes.NewBufferString("")
-	err = tmplHTML.Execute(htmlWriter, vars)
-	if err != nil {
-		return
+	// Аватар встраиваем в письмо: внешние картинки большинство почтовых клиентов по умолчанию не показывают.
+	var inline []email.Attachment
+	if adminAvatar != "" {
+		image, err := email.FetchImage(adminAvatar, avatarContentID, internal.Config.AccountMediaHost)
+		if err == nil {
+			inline = append(inline, image)
+			vars.Admin.Avatar = "cid:" + avatarContentID
+		}
 	}
 
-	textWriter := bytes.NewBufferString("")
-	err = tmplText.Execute(textWriter, vars)
-	if err != nil {
-		return
-	}
+	return renderInviteMessage(templates, locale, to, vars, inline)
+}
 
-	varsBase := struct{
-		To string
-		From TemplateUser
-		HtmlPart string
-		TextPart string
-	}{
-		to,
-		TemplateUser{
-			FullName: "Pay Together",
-			Email:    internal.Config.InviteLetterSender,
-		},
-		base64.StdEncoding.EncodeToString(htmlWriter.Bytes()),
-		base64.StdEncoding.EncodeToString(textWriter.Bytes()),
+func renderInviteMessage(templates templateRegistry, locale string, to string, vars inviteTemplateVars,
+	inline []email.Attachment) (message []byte, err error) {
+	msg := email.Message{
+		From:   mail.Address{Name: "Pay Together", Address: internal.Config.InviteLetterSender},
+		To:     []mail.Address{{Address: to}},
+		Inline: inline,
 	}
 
-	tmplBase, err := template.ParseFiles(internal.Config.InviteLetterBasePath + "/invite.eml")
+	msg.HTML, err = templates.Execute(locale, inviteTemplateHTML, vars)
 	if err != nil {
 		return
 	}
-	baseWriter := bytes.NewBufferString("")
-	err = tmplBase.Execute(baseWriter, varsBase)
+	msg.Text, err = templates.Execute(locale, inviteTemplateText, vars)
 	if err != nil {
 		return
 	}
+	subject, err := templates.Execute(locale, inviteTemplateSubject, vars)
+	if err != nil {
+		return
+	}
+	msg.Subject = string(subject)
 
-	queueInit.Do(func(){
-		queue.Init(internal.Config.InviteLetterTimespan)
-	})
-
-	queue.Enqueue(Package{
-		Host:    host,
-		From:    from,
-		To:      to,
-		Message: baseWriter.Bytes(),
-	})
-
-	go doSend()
-
-	//auth := smtp.PlainAuth("", from, internal.Config.InviteLetterSenderPassword, host)
-	//if err := smtp.SendMail(host+":25", auth, from, []string{to}, baseWriter.Bytes()); err != nil {
-	//	return err
-	//}
+	msg.ListUnsubscribe = unsubscribeAddresses(internal.Config.InviteLetterUnsubscribe)
 
-	return nil
+	return msg.Bytes()
 }
 
-func doSend() {
-	p := queue.Dequeue()
-	auth := smtp.PlainAuth("", p.From, internal.Config.InviteLetterSenderPassword, p.Host)
-	if err := smtp.SendMail(p.Host+":25", auth, p.From, []string{p.To}, p.Message); err != nil {
-		fmt.Println("Mailer: cannot send email to ", p.To, err)
-		p.Retries +=1
-		if p.Retries < maxFails {
-			queue.Enqueue(p)
+// unsubscribeAddresses разбирает список адресов через запятую, пропуская пробелы и пустые элементы.
+func unsubscribeAddresses(value string) (addresses []string) {
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
 		}
-		queue.timespan *= 3
-		return
 	}
-	fmt.Println("Mailer: message sent to ", p.To)
-	queue.timespan = internal.Config.InviteLetterTimespan
-}
\ No newline at end of file
+	return
+}
@@ -0,0 +1,22 @@
+package group
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnsubscribeAddresses(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected []string
+	}{
+		{"", nil},
+		{" mailto:a@pay-together.ru , https://pay-together.ru/u ,", []string{"mailto:a@pay-together.ru", "https://pay-together.ru/u"}},
+		{"mailto:a@pay-together.ru", []string{"mailto:a@pay-together.ru"}},
+	}
+	for _, c := range cases {
+		if addresses := unsubscribeAddresses(c.value); !reflect.DeepEqual(addresses, c.expected) {
+			t.Errorf("%q: got %q, want %q", c.value, addresses, c.expected)
+		}
+	}
+}
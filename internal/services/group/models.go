@@ -3,8 +3,10 @@ package group
 import (
 	account "github.com/Solar-2020/Account-Backend/pkg/models"
 	"github.com/Solar-2020/Group-Backend/internal/models"
+	storage "github.com/Solar-2020/Group-Backend/internal/storages/groupStorage"
 	group "github.com/Solar-2020/Group-Backend/pkg/models"
 	"github.com/pkg/errors"
+	"time"
 )
 
 var (
@@ -15,26 +17,82 @@ type groupStorage interface {
 	InsertGroup(group group.Group) (groupReturn group.Group, err error)
 	UpdateGroup(group group.Group) (groupReturn group.Group, err error)
 	UpdateGroupStatus(groupID, statusID int) (group group.Group, err error)
+	SelectDeletedGroups(userID, roleID int, deletedAfter time.Time) (groups []group.DeletedGroup, err error)
+	PurgeDeletedGroups(deletedBefore time.Time, limit int) (groupIDs []int, err error)
 	SelectGroupByID(groupID int) (group group.Group, err error)
+	SelectGroupIDBySlug(slug string) (groupID int, err error)
 	SelectGroupRole(groupID, userID int) (role group.UserRole, err error)
 	SelectPermission(actionID, roleID int) (permission models.Permission, err error)
-	SelectGroupsByUserID(userID int, groupID int) (group []group.GroupPreview, err error)
+	SelectGroupsByUserID(userID int, groupID int, listParams models.ListParams) (groups []group.GroupPreview, page models.Page, err error)
+	SelectPublicGroups(listParams models.ListParams) (groups []group.GroupPreview, nextCursor string, err error)
+	SearchGroups(userID int, tsQuery, scope string, limit, offset int) (groups []group.GroupPreview, err error)
 
-	SelectUsersByGroupID(groupID int) (users []group.UserRole, err error)
+	SelectUsersByGroupID(groupID int, listParams models.ListParams) (users []group.UserRole, page models.Page, err error)
 	InsertUser(groupID, userID, roleID int) (err error)
 	EditUserRole(groupID, userID, roleID int) (resultRole int, err error)
 	RemoveUser(groupID, userID int) (err error)
 
-	HashToGroupID(line string) (groupID int, err error)
+	SelectLinkByHash(line string) (link group.GroupInviteLink, err error)
+	UseLink(line string) (err error)
 	RemoveLinkToGroup(groupID int, link string) (err error)
 	ListShortLinksToGroup(groupID int) (res []group.GroupInviteLink, err error)
-	AddShortLinkToGroup(groupID int, link string, author int) (err error)
+	AddShortLinkToGroup(groupID int, link group.GroupInviteLink) (err error)
+
+	SelectRoles(groupID int) (roles []group.Role, err error)
+	SelectRole(groupID, roleID int) (role group.Role, err error)
+	InsertRole(role group.Role) (roleReturn group.Role, err error)
+	UpdateRole(role group.Role) (roleReturn group.Role, err error)
+	DeleteRole(groupID, roleID int) (err error)
+	CountUsersWithRole(groupID, roleID int) (count int, err error)
+	CountRoleReferences(groupID, roleID int) (count int, err error)
+
+	InsertTransfer(transfer group.OwnershipTransfer) (transferReturn group.OwnershipTransfer, err error)
+	SelectTransfer(transferID int) (transfer group.OwnershipTransfer, err error)
+	SelectTransfersByUserID(userID int) (transfers []group.OwnershipTransfer, err error)
+	CancelTransfer(transferID int) (err error)
+	AcceptTransfer(transfer group.OwnershipTransfer, creatorRoleID, adminRoleID int) (err error)
+
+	InsertJoinRequest(groupID, userID int) (request group.JoinRequest, err error)
+	SelectJoinRequests(groupID int) (requests []group.JoinRequest, err error)
+	SelectJoinRequestsByUserID(userID int) (requests []group.JoinRequest, err error)
+	ApproveJoinRequest(groupID, requestID, roleID, actorID int) (request group.JoinRequest, err error)
+	RejectJoinRequest(groupID, requestID, actorID int) (request group.JoinRequest, err error)
+
+	InsertInvitation(invitation group.Invitation) (invitationReturn group.Invitation, err error)
+	SelectInvitationsByUserID(userID int) (invitations []group.Invitation, err error)
+	SelectInvitationsByGroupID(groupID int) (invitations []group.Invitation, err error)
+	AcceptInvitation(invitationID, userID int) (invitation group.Invitation, err error)
+	DeclineInvitation(invitationID, userID int) (invitation group.Invitation, err error)
+	RevokeInvitation(groupID, invitationID int) (invitation group.Invitation, err error)
+	SelectInvitation(invitationID int) (invitation group.Invitation, err error)
+	SelectInvitationByLink(link string) (invitation group.Invitation, err error)
+	SetInvitationMessage(invitationID, messageID int, link string) (previousLink string, err error)
+	SetInvitationDeliveryError(invitationID int, reason string) (err error)
+
+	InsertAuditEntry(entry group.AuditEntry) (err error)
+	SelectAuditEntries(request models.AuditListRequest) (entries []group.AuditEntry, page models.Page, err error)
+
+	WithTx(fn func(tx storage.Storage) error) (err error)
+}
+
+type outbox interface {
+	InsertMessage(message models.OutboxMessage) (messageReturn models.OutboxMessage, err error)
+}
+
+type templateRegistry interface {
+	Execute(locale, name string, vars interface{}) (result []byte, err error)
+	Locales() (locales []string)
+}
+
+type tokenGenerator interface {
+	Generate() (token string, err error)
 }
 
 type accountClient interface {
 	GetUserByUid(userID int) (user account.User, err error)
 	GetUserByEmail(email string) (user account.User, err error)
 	CreateUserAdvance(request account.UserAdvance) (user account.User, err error)
+	GetUsersByUids(userIDs []int) (users map[int]account.User, err error)
 }
 
 type errorWorker interface {
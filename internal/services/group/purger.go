@@ -0,0 +1,86 @@
+package group
+
+import (
+	"github.com/rs/zerolog"
+	"sync"
+	"time"
+)
+
+const purgeBatchSize = 100
+
+type PurgerConfig struct {
+	Interval time.Duration
+}
+
+// Purger периодически удаляет группы, срок хранения которых после удаления истёк.
+// Несколько экземпляров сервиса могут работать одновременно: заблокированные другим экземпляром группы пропускаются.
+type Purger interface {
+	Run()
+	Shutdown()
+}
+
+type purger struct {
+	groupService Service
+	config       PurgerConfig
+	log          *zerolog.Logger
+	stop         chan struct{}
+	done         chan struct{}
+	stopOnce     sync.Once
+}
+
+func NewPurger(groupService Service, config PurgerConfig, log *zerolog.Logger) Purger {
+	return &purger{
+		groupService: groupService,
+		config:       config,
+		log:          log,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+func (p *purger) Run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge()
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown дожидается окончания удаления текущей пачки групп.
+func (p *purger) Shutdown() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+func (p *purger) purge() {
+	for {
+		groupIDs, err := p.groupService.PurgeDeletedGroups(purgeBatchSize)
+		if err != nil {
+			p.log.Error().Str("msg", "purger: cannot purge deleted groups").Err(err).Send()
+			return
+		}
+		if len(groupIDs) > 0 {
+			p.log.Info().Str("msg", "purger: deleted groups purged").Ints("groups", groupIDs).Send()
+		}
+		if len(groupIDs) < purgeBatchSize {
+			return
+		}
+
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package group
+
+import (
+	"database/sql"
+	"github.com/Solar-2020/Group-Backend/internal"
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+	"github.com/valyala/fasthttp"
+	"time"
+)
+
+// groupRetention - срок, в течение которого удалённую группу можно восстановить. После него группу удаляет Purger.
+func groupRetention() time.Duration {
+	return time.Duration(internal.Config.GroupDeleteRetention) * time.Hour
+}
+
+// GetDeletedList возвращает группы пользователя, которые он удалил как создатель и ещё может восстановить.
+func (s *service) GetDeletedList(userID int) (groups []models2.DeletedGroup, err error) {
+	retention := groupRetention()
+	groups, err = s.groupStorage.SelectDeletedGroups(userID, int(models2.RoleCreator), time.Now().Add(-retention))
+	if err != nil {
+		return
+	}
+
+	for i := range groups {
+		groups[i].RestoreUntil = groups[i].DeletedAt.Add(retention)
+	}
+	return
+}
+
+func (s *service) Restore(groupID, userID int, requestID string) (response models2.Group, err error) {
+	err = s.inTx(func(s *service) (err error) {
+		deleted, err := s.GetDeletedList(userID)
+		if err != nil {
+			return
+		}
+		if !containsDeletedGroup(deleted, groupID) {
+			return models.ErrorNotRestorable
+		}
+
+		response, err = s.groupStorage.UpdateGroupStatus(groupID, models2.GroupActive)
+		if err != nil {
+			return
+		}
+
+		before := newAuditGroup(response)
+		before.StatusID = models2.GroupDeleted
+		return s.audit(models2.AuditEntry{
+			GroupID:    groupID,
+			ActorID:    userID,
+			Action:     models2.AuditGroupRestore,
+			TargetType: auditTargetGroup,
+			TargetID:   auditID(groupID),
+			RequestID:  requestID,
+		}, before, newAuditGroup(response))
+	})
+	// sql.ErrNoRows - группу окончательно удалили между проверкой и восстановлением.
+	if err == models.ErrorNotRestorable || err == sql.ErrNoRows {
+		return models2.Group{}, s.errorWorker.NewError(fasthttp.StatusNotFound, models.ErrorNotRestorable, err)
+	}
+	return
+}
+
+func containsDeletedGroup(groups []models2.DeletedGroup, groupID int) bool {
+	for _, group := range groups {
+		if group.ID == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// PurgeDeletedGroups окончательно удаляет не больше limit групп с истёкшим сроком хранения.
+// Записи журнала аудита сохраняются; удаление записывается в журнал от имени системы с ActorID = 0.
+func (s *service) PurgeDeletedGroups(limit int) (groupIDs []int, err error) {
+	err = s.inTx(func(s *service) (err error) {
+		groupIDs, err = s.groupStorage.PurgeDeletedGroups(time.Now().Add(-groupRetention()), limit)
+		if err != nil {
+			return
+		}
+
+		for _, groupID := range groupIDs {
+			err = s.audit(models2.AuditEntry{
+				GroupID:    groupID,
+				Action:     models2.AuditGroupPurge,
+				TargetType: auditTargetGroup,
+				TargetID:   auditID(groupID),
+			}, nil, nil)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
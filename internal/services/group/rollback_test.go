@@ -0,0 +1,148 @@
+package group
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	storage "github.com/Solar-2020/Group-Backend/internal/storages/groupStorage"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+)
+
+var errStepFailed = errors.New("step failed")
+
+// failingStorage возвращает errStepFailed из метода failOn после skip успешных вызовов,
+// в том числе внутри транзакций.
+type failingStorage struct {
+	storage.Storage
+	failOn string
+	skip   *int
+}
+
+func newFailingStorage(s storage.Storage, failOn string, skip int) *failingStorage {
+	return &failingStorage{Storage: s, failOn: failOn, skip: &skip}
+}
+
+func (s *failingStorage) fail(method string) error {
+	if method != s.failOn {
+		return nil
+	}
+	if *s.skip > 0 {
+		*s.skip--
+		return nil
+	}
+	return errStepFailed
+}
+
+func (s *failingStorage) WithTx(fn func(tx storage.Storage) error) error {
+	return s.Storage.WithTx(func(tx storage.Storage) error {
+		return fn(&failingStorage{Storage: tx, failOn: s.failOn, skip: s.skip})
+	})
+}
+
+func (s *failingStorage) InsertUser(groupID, userID, roleID int) (err error) {
+	if err = s.fail("InsertUser"); err != nil {
+		return
+	}
+	return s.Storage.InsertUser(groupID, userID, roleID)
+}
+
+func (s *failingStorage) InsertInvitation(invitation models2.Invitation) (models2.Invitation, error) {
+	if err := s.fail("InsertInvitation"); err != nil {
+		return models2.Invitation{}, err
+	}
+	return s.Storage.InsertInvitation(invitation)
+}
+
+func (s *failingStorage) InsertAuditEntry(entry models2.AuditEntry) (err error) {
+	if err = s.fail("InsertAuditEntry"); err != nil {
+		return
+	}
+	return s.Storage.InsertAuditEntry(entry)
+}
+
+func TestCreateRollsBack(t *testing.T) {
+	f := newServiceFixture(t)
+	f.service.groupStorage = newFailingStorage(f.storage, "InsertUser", 0)
+
+	_, err := f.service.Create(models2.Group{Title: "Без создателя", URL: "orphan", CreateBy: testCreatorID})
+	if err != errStepFailed {
+		t.Fatalf("got %v, want %v", err, errStepFailed)
+	}
+	if _, err = f.storage.SelectGroupIDBySlug("orphan"); err != sql.ErrNoRows {
+		t.Errorf("group without creator persisted: %v", err)
+	}
+}
+
+func TestResolveGroupRollsBack(t *testing.T) {
+	f := newServiceFixture(t)
+	link := models2.GroupInviteLink{Link: "once", Author: models2.AuthorPack{ID: testCreatorID}, RoleID: int(models2.RoleDweller),
+		MaxUses: 1}
+	if err := f.storage.AddShortLinkToGroup(f.groupID, link); err != nil {
+		t.Fatal(err)
+	}
+	f.service.groupStorage = newFailingStorage(f.storage, "InsertUser", 0)
+
+	_, err := f.service.ResolveGroup(models.ResolveInviteLinkRequest{Link: "http://host/once", UserID: 42})
+	if err != errStepFailed {
+		t.Fatalf("got %v, want %v", err, errStepFailed)
+	}
+
+	stored, err := f.storage.SelectLinkByHash(link.Link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Uses != 0 {
+		t.Errorf("link used %d times without joining", stored.Uses)
+	}
+	if _, err = f.storage.SelectGroupRole(f.groupID, 42); err != sql.ErrNoRows {
+		t.Errorf("user joined: %v", err)
+	}
+}
+
+func TestInviteRollsBack(t *testing.T) {
+	f := newServiceFixture(t)
+	// Первое приглашение создаётся, второе - нет.
+	f.service.groupStorage = newFailingStorage(f.storage, "InsertInvitation", 1)
+
+	_, err := f.service.Invite(models.InviteUserRequest{
+		Group:     f.groupID,
+		CreatorID: testCreatorID,
+		UserID:    []int{42, 43},
+	})
+	if err == nil {
+		t.Fatal("invite succeeded")
+	}
+
+	invitations, err := f.storage.SelectInvitationsByGroupID(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 0 {
+		t.Errorf("%d invitations persisted", len(invitations))
+	}
+}
+
+func TestChangeRoleRollsBack(t *testing.T) {
+	f := newServiceFixture(t)
+	f.service.groupStorage = newFailingStorage(f.storage, "InsertAuditEntry", 0)
+
+	_, err := f.service.ChangeRole(models.ChangeRoleRequest{
+		ActorID: testCreatorID,
+		UserID:  testDwellerID,
+		Group:   f.groupID,
+		Role:    models2.RoleAdmin,
+	})
+	if err != errStepFailed {
+		t.Fatalf("got %v, want %v", err, errStepFailed)
+	}
+
+	userRole, err := f.storage.SelectGroupRole(f.groupID, testDwellerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userRole.RoleID != int(models2.RoleDweller) {
+		t.Errorf("role changed to %d without an audit entry", userRole.RoleID)
+	}
+}
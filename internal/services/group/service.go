@@ -7,22 +7,32 @@ import (
 	models3 "github.com/Solar-2020/Account-Backend/pkg/models"
 	"github.com/Solar-2020/Group-Backend/internal"
 	"github.com/Solar-2020/Group-Backend/internal/models"
+	storage "github.com/Solar-2020/Group-Backend/internal/storages/groupStorage"
 	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+	"github.com/rs/zerolog"
 	"github.com/valyala/fasthttp"
-	"math/rand"
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type Service interface {
 	Create(request models2.Group) (response models2.Group, err error)
-	Update(request models2.Group, userID int) (response models2.Group, err error)
-	Delete(groupID, userID int) (response models2.Group, err error)
+	Update(request models2.Group, userID int, requestID string) (response models2.Group, err error)
+	Delete(groupID, userID int, requestID string) (response models2.Group, err error)
+	Restore(groupID, userID int, requestID string) (response models2.Group, err error)
+	GetDeletedList(userID int) (groups []models2.DeletedGroup, err error)
+	PurgeDeletedGroups(limit int) (groupIDs []int, err error)
 	Get(groupID, userID int) (response models2.Group, err error)
-	GetList(groupID, userID int) (response []models2.GroupPreview, err error)
+	GetBySlug(slug string, userID int) (response models2.Group, err error)
+	GetPreview(request models.GetGroupRequest) (response models2.GroupPreview, err error)
+	Discover(request models.DiscoverRequest) (response models.DiscoverResponse, err error)
+	SearchGroups(request models.SearchGroupsRequest) (response models.SearchGroupsResponse, err error)
+	CheckSlug(slug string) (response models.SlugAvailabilityResponse, err error)
+	GetList(request models.GroupListRequest) (response []models2.GroupPreview, page models.Page, err error)
 
-	InternalGetList(groupID, userID int) (response []models2.GroupPreview, err error)
+	InternalGetList(request models.GroupListRequest) (response []models2.GroupPreview, page models.Page, err error)
 
 	Invite(request models.InviteUserRequest) (response models.InviteUserResponse, err error)
 	ChangeRole(request models.ChangeRoleRequest) (response models.ChangeRoleResponse, err error)
@@ -37,28 +47,81 @@ type Service interface {
 
 	GetUserRole(groupID, userID int) (role models2.UserRole, err error)
 
-	GetMembershipList(groupID, userID int) (role []models2.Membership, err error)
+	GetMembershipList(request models.MembershipListRequest) (role []models2.Membership, page models.Page, err error)
+
+	GetUserInvitations(userID int) (invitations []models2.Invitation, err error)
+	AcceptInvitation(request models.InvitationActionRequest) (response models2.Invitation, err error)
+	DeclineInvitation(request models.InvitationActionRequest) (response models2.Invitation, err error)
+	RevokeInvitation(request models.InvitationActionRequest) (response models2.Invitation, err error)
+	ResendInvitation(request models.ResendInvitationRequest) (response models2.Invitation, err error)
+
+	GetRoles(groupID, userID int) (roles []models2.Role, err error)
+	CreateRole(request models.CreateRoleRequest) (response models2.Role, err error)
+	UpdateRole(request models.UpdateRoleRequest) (response models2.Role, err error)
+	DeleteRole(request models.DeleteRoleRequest) (response models.DeleteRoleResponse, err error)
+
+	ProposeTransfer(request models.ProposeTransferRequest) (response models2.OwnershipTransfer, err error)
+	GetTransferList(userID int) (response []models2.OwnershipTransfer, err error)
+	AcceptTransfer(request models.TransferActionRequest) (response models2.OwnershipTransfer, err error)
+	CancelTransfer(request models.TransferActionRequest) (response models2.OwnershipTransfer, err error)
+
+	GetJoinRequests(groupID, userID int) (requests []models2.JoinRequest, err error)
+	GetUserJoinRequests(userID int) (requests []models2.JoinRequest, err error)
+	ApproveJoinRequest(request models.JoinRequestDecisionRequest) (response models2.JoinRequest, err error)
+	RejectJoinRequest(request models.JoinRequestDecisionRequest) (response models2.JoinRequest, err error)
+
+	PreviewTemplate(request models.TemplatePreviewRequest) (response models.TemplatePreviewResponse, err error)
+
+	GetAuditLog(request models.AuditListRequest) (entries []models2.AuditEntry, page models.Page, err error)
 }
 
+const (
+	maxLinkInsertAttempts = 5
+	defaultDiscoverLimit  = 20
+	maxDiscoverLimit      = 100
+)
+
 var (
-	inviteHashParse = regexp.MustCompile(`http(?:s)?:\/\/.*\/(\w+)(?:\/)?`)
+	inviteHashParse = regexp.MustCompile(`http(?:s)?:\/\/.*\/([\w-]+)(?:\/)?`)
+	// Ссылка начинается с буквы, чтобы её нельзя было спутать с числовым ID группы.
+	slugPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+	// reservedSlugs совпадают с путями фронтенда и API.
+	reservedSlugs = map[string]bool{
+		"api": true, "admin": true, "group": true, "groups": true, "list": true, "new": true, "create": true,
+		"edit": true, "settings": true, "invite": true, "join": true, "login": true, "logout": true,
+		"signup": true, "profile": true, "discover": true, "search": true, "help": true, "support": true,
+		"about": true, "static": true,
+	}
 )
 
 type service struct {
-	groupStorage  groupStorage
-	accountClient accountClient
-	errorWorker   errorWorker
+	groupStorage   groupStorage
+	accountClient  accountClient
+	errorWorker    errorWorker
+	tokenGenerator tokenGenerator
+	outbox         outbox
+	templates      templateRegistry
+	log            *zerolog.Logger
 }
 
-func NewService(groupStorage groupStorage, accountClient accountClient, errorWorker errorWorker) Service {
+func NewService(groupStorage groupStorage, accountClient accountClient, errorWorker errorWorker, tokenGenerator tokenGenerator,
+	outbox outbox, templates templateRegistry, log *zerolog.Logger) Service {
 	return &service{
-		groupStorage:  groupStorage,
-		accountClient: accountClient,
-		errorWorker:   errorWorker,
+		groupStorage:   groupStorage,
+		accountClient:  accountClient,
+		errorWorker:    errorWorker,
+		tokenGenerator: tokenGenerator,
+		outbox:         outbox,
+		templates:      templates,
+		log:            log,
 	}
 }
 
 func (s *service) Create(request models2.Group) (response models2.Group, err error) {
+	request.URL = normalizeSlug(request.URL)
+	if request.VisibilityID == 0 {
+		request.VisibilityID = models2.VisibilityPrivate
+	}
 	err = s.validateGroup(request)
 	if err != nil {
 		return
@@ -69,18 +132,43 @@ func (s *service) Create(request models2.Group) (response models2.Group, err err
 		return
 	}
 
-	response, err = s.groupStorage.InsertGroup(request)
-	if err != nil {
-		return
+	if request.JoinRoleID == 0 {
+		request.JoinRoleID = int(models2.RoleDweller)
+	}
+	if request.JoinRoleID != int(models2.RoleAdmin) && request.JoinRoleID != int(models2.RoleDweller) {
+		return response, models.ErrorRoleNotFound
 	}
 
-	err = s.groupStorage.InsertUser(response.ID, response.CreateBy, 1)
+	// Группа без создателя недопустима, поэтому оба шага выполняются в одной транзакции.
+	err = s.inTx(func(s *service) (err error) {
+		response, err = s.groupStorage.InsertGroup(request)
+		if err != nil {
+			return
+		}
+		return s.groupStorage.InsertUser(response.ID, response.CreateBy, int(models2.RoleCreator))
+	})
+	if err == models.ErrorDuplicate {
+		return models2.Group{}, s.errorWorker.NewError(fasthttp.StatusConflict, models.ErrorSlugTaken, err)
+	}
+	if err != nil {
+		return models2.Group{}, err
+	}
 	return
 }
 
+// inTx выполняет fn в транзакции хранилища: внутри fn все обращения s.groupStorage идут через неё.
+func (s *service) inTx(fn func(s *service) error) error {
+	return s.groupStorage.WithTx(func(tx storage.Storage) error {
+		txService := *s
+		txService.groupStorage = tx
+		return fn(&txService)
+	})
+}
+
 func (s *service) validateGroup(group models2.Group) (err error) {
-	if len(group.URL) < 3 || len(group.URL) > 20 {
-		return errors.New("Недопустимая длина ссылки")
+	err = validateSlug(group.URL)
+	if err != nil {
+		return
 	}
 
 	if len(group.Description) > 500 {
@@ -91,46 +179,85 @@ func (s *service) validateGroup(group models2.Group) (err error) {
 		return errors.New("Слишком длинное название")
 	}
 
+	if group.VisibilityID < models2.VisibilityPrivate || group.VisibilityID > models2.VisibilityPublic {
+		return models.ErrorBadVisibility
+	}
+
 	return
 }
 
+// checkUnique даёт понятную ошибку в обычном случае; одновременные запросы с одной ссылкой
+// разрешает уникальный индекс по groups.url.
 func (s *service) checkUnique(group models2.Group) (err error) {
-
+	groupID, err := s.groupStorage.SelectGroupIDBySlug(group.URL)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if groupID != group.ID {
+		return s.errorWorker.NewError(fasthttp.StatusConflict, models.ErrorSlugTaken, models.ErrorSlugTaken)
+	}
 	return
 }
 
-func (s *service) checkAdminPermission(groupID, userID int) (err error) {
-	role, err := s.groupStorage.SelectGroupRole(groupID, userID)
-	if err != nil {
-		return errors.New("У Вас недостаточно прав для совершения данной операции")
-	}
+func normalizeSlug(slug string) string {
+	return strings.ToLower(strings.TrimSpace(slug))
+}
 
-	if !(role.RoleID == 1 || role.RoleID == 2) {
-		return errors.New("У Вас недостаточно прав для совершения данной операции")
+func validateSlug(slug string) (err error) {
+	if len(slug) < 3 || len(slug) > 20 {
+		return errors.New("Недопустимая длина ссылки")
+	}
+	if !slugPattern.MatchString(slug) {
+		return models.ErrorSlugInvalid
+	}
+	if reservedSlugs[slug] {
+		return models.ErrorSlugReserved
 	}
-
 	return
 }
 
-func (s *service) checkUserPermission(groupID, userID int) (err error) {
-	role, err := s.groupStorage.SelectGroupRole(groupID, userID)
+func (s *service) CheckSlug(slug string) (response models.SlugAvailabilityResponse, err error) {
+	response.Slug = normalizeSlug(slug)
+
+	err = validateSlug(response.Slug)
 	if err != nil {
-		return errors.New("У Вас недостаточно прав для совершения данной операции")
+		response.Reason = err.Error()
+		return response, nil
 	}
 
-	if !(role.RoleID == 1 || role.RoleID == 2 || role.RoleID == 3) {
-		return errors.New("У Вас недостаточно прав для совершения данной операции")
+	_, err = s.groupStorage.SelectGroupIDBySlug(response.Slug)
+	if err == sql.ErrNoRows {
+		response.Available = true
+		return response, nil
 	}
-
+	if err != nil {
+		return
+	}
+	response.Reason = models.ErrorSlugTaken.Error()
 	return
 }
 
-func (s *service) Update(request models2.Group, userID int) (response models2.Group, err error) {
-	err = s.checkAdminPermission(request.ID, userID)
+func (s *service) checkPermission(groupID, userID, actionID int) (err error) {
+	return s.CheckPermission(models2.GroupAction{
+		GroupID:  groupID,
+		UserID:   userID,
+		ActionID: actionID,
+	})
+}
+
+func (s *service) Update(request models2.Group, userID int, requestID string) (response models2.Group, err error) {
+	err = s.checkPermission(request.ID, userID, models2.ActionEditGroup)
 	if err != nil {
 		return
 	}
 
+	request.URL = normalizeSlug(request.URL)
+	if request.VisibilityID == 0 {
+		request.VisibilityID = models2.VisibilityPrivate
+	}
 	err = s.validateGroup(request)
 	if err != nil {
 		return
@@ -141,24 +268,72 @@ func (s *service) Update(request models2.Group, userID int) (response models2.Gr
 		return
 	}
 
-	response, err = s.groupStorage.UpdateGroup(request)
+	if request.JoinRoleID == 0 {
+		request.JoinRoleID = int(models2.RoleDweller)
+	}
+	if request.JoinRoleID == int(models2.RoleCreator) {
+		return response, models.ErrorRoleRank
+	}
+	err = s.checkAssignableRole(request.ID, userID, request.JoinRoleID)
+	if err != nil {
+		return
+	}
+
+	err = s.inTx(func(s *service) (err error) {
+		before, err := s.groupStorage.SelectGroupByID(request.ID)
+		if err != nil {
+			return
+		}
+		response, err = s.groupStorage.UpdateGroup(request)
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.ID,
+			ActorID:    userID,
+			Action:     models2.AuditGroupUpdate,
+			TargetType: auditTargetGroup,
+			TargetID:   auditID(request.ID),
+			RequestID:  requestID,
+		}, newAuditGroup(before), newAuditGroup(response))
+	})
+	if err == models.ErrorDuplicate {
+		return response, s.errorWorker.NewError(fasthttp.StatusConflict, models.ErrorSlugTaken, err)
+	}
 
 	return
 }
 
-func (s *service) Delete(groupID, userID int) (response models2.Group, err error) {
-	err = s.checkAdminPermission(groupID, userID)
+func (s *service) Delete(groupID, userID int, requestID string) (response models2.Group, err error) {
+	err = s.checkPermission(groupID, userID, models2.ActionDeleteGroup)
 	if err != nil {
 		return
 	}
 
-	response, err = s.groupStorage.UpdateGroupStatus(groupID, 2)
+	err = s.inTx(func(s *service) (err error) {
+		before, err := s.groupStorage.SelectGroupByID(groupID)
+		if err != nil {
+			return
+		}
+		response, err = s.groupStorage.UpdateGroupStatus(groupID, models2.GroupDeleted)
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    groupID,
+			ActorID:    userID,
+			Action:     models2.AuditGroupDelete,
+			TargetType: auditTargetGroup,
+			TargetID:   auditID(groupID),
+			RequestID:  requestID,
+		}, newAuditGroup(before), newAuditGroup(response))
+	})
 
 	return
 }
 
 func (s *service) Get(groupID, userID int) (response models2.Group, err error) {
-	err = s.checkUserPermission(groupID, userID)
+	err = s.checkPermission(groupID, userID, models2.ActionViewGroup)
 	if err != nil {
 		return
 	}
@@ -171,18 +346,171 @@ func (s *service) Get(groupID, userID int) (response models2.Group, err error) {
 	return
 }
 
-func (s *service) GetList(groupID, userID int) (response []models2.GroupPreview, err error) {
-	response, err = s.groupStorage.SelectGroupsByUserID(userID, groupID)
+// GetPreview отдаёт краткие сведения о группе: участникам - любой, остальным - публичной, а группе,
+// доступной по ссылке, - только если её нашли по ссылке группы или пригласительной ссылке. ID групп идут подряд,
+// поэтому по числовому ID посторонний получает только публичные группы. О существовании остальных он не узнает.
+func (s *service) GetPreview(request models.GetGroupRequest) (response models2.GroupPreview, err error) {
+	byReference := request.Slug != "" || request.Link != ""
+	if request.Slug != "" {
+		request.Group, err = s.groupStorage.SelectGroupIDBySlug(normalizeSlug(request.Slug))
+		if err == sql.ErrNoRows {
+			return response, s.errorWorker.NewError(fasthttp.StatusNotFound, models.ErrorNoGroup, err)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if request.Link != "" {
+		linkHash, _ := s.getHashFromLink(request.Link)
+		link, err := s.selectValidLink(linkHash)
+		if err != nil || request.Group != 0 && request.Group != link.GroupID {
+			return response, s.errorWorker.NewError(fasthttp.StatusNotFound, models.ErrorNoGroup, models.ErrorNoGroup)
+		}
+		request.Group = link.GroupID
+	}
+
+	group, err := s.groupStorage.SelectGroupByID(request.Group)
+	if err == sql.ErrNoRows {
+		return response, s.errorWorker.NewError(fasthttp.StatusNotFound, models.ErrorNoGroup, err)
+	}
+	if err != nil {
+		return
+	}
+
+	role, err := s.groupStorage.SelectGroupRole(group.ID, request.UserID)
+	if err == sql.ErrNoRows {
+		visible := group.VisibilityID == models2.VisibilityPublic ||
+			group.VisibilityID == models2.VisibilityUnlisted && byReference
+		if !visible {
+			return response, s.errorWorker.NewError(fasthttp.StatusNotFound, models.ErrorNoGroup, models.ErrorNoGroup)
+		}
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
+	return models2.GroupPreview{
+		ID:           group.ID,
+		Title:        group.Title,
+		Description:  group.Description,
+		URL:          group.URL,
+		AvatarURL:    group.AvatarURL,
+		UserID:       request.UserID,
+		UserRole:     role,
+		Status:       group.StatusID,
+		Count:        group.Count,
+		VisibilityID: group.VisibilityID,
+	}, nil
+}
+
+func (s *service) Discover(request models.DiscoverRequest) (response models.DiscoverResponse, err error) {
+	if request.Limit <= 0 || request.Limit > maxDiscoverLimit {
+		request.Limit = defaultDiscoverLimit
+	}
+
+	response.Groups, response.NextCursor, err = s.groupStorage.SelectPublicGroups(models.ListParams{
+		Limit:  request.Limit,
+		Cursor: request.Cursor,
+		SortBy: models.SortByMembers,
+		Desc:   true,
+	})
+	if err != nil {
+		return response, s.listError(err)
+	}
+	return
+}
+
+func (s *service) SearchGroups(request models.SearchGroupsRequest) (response models.SearchGroupsResponse, err error) {
+	response.Groups = make([]models2.GroupPreview, 0)
+	if request.Limit <= 0 || request.Limit > maxDiscoverLimit {
+		request.Limit = defaultDiscoverLimit
+	}
+	if request.Offset < 0 {
+		request.Offset = 0
+	}
+
+	tsQuery := prefixTSQuery(request.Query)
+	if tsQuery == "" {
+		return
+	}
+
+	response.Groups, err = s.groupStorage.SearchGroups(request.UserID, tsQuery, request.Scope, request.Limit+1, request.Offset)
+	if err != nil {
+		return
+	}
+	if len(response.Groups) > request.Limit {
+		response.Groups = response.Groups[:request.Limit]
+		response.NextOffset = request.Offset + request.Limit
+	}
+	return
+}
+
+// prefixTSQuery превращает пользовательский ввод в выражение to_tsquery, где каждое слово ищется по префиксу:
+// "пут мос" -> "пут:* & мос:*". Всё, кроме букв и цифр, отбрасывается, чтобы ввод не ломал синтаксис tsquery.
+func prefixTSQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := range words {
+		words[i] += ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+func (s *service) GetBySlug(slug string, userID int) (response models2.Group, err error) {
+	groupID, err := s.groupStorage.SelectGroupIDBySlug(normalizeSlug(slug))
+	if err != nil {
+		return
+	}
+	return s.Get(groupID, userID)
+}
+
+func (s *service) GetList(request models.GroupListRequest) (response []models2.GroupPreview, page models.Page, err error) {
+	normalizeListParams(&request.ListParams, models.SortByTitle)
+	response, page, err = s.groupStorage.SelectGroupsByUserID(request.UserID, request.Group, request.ListParams)
+	err = s.listError(err)
 	return
 }
 
-func (s *service) InternalGetList(groupID, userID int) (response []models2.GroupPreview, err error) {
-	response, err = s.groupStorage.SelectGroupsByUserID(userID, groupID)
+func (s *service) InternalGetList(request models.GroupListRequest) (response []models2.GroupPreview, page models.Page, err error) {
+	normalizeListParams(&request.ListParams, models.SortByTitle)
+	response, page, err = s.groupStorage.SelectGroupsByUserID(request.UserID, request.Group, request.ListParams)
+	err = s.listError(err)
 	return
 }
 
+func normalizeListParams(params *models.ListParams, defaultSort string) {
+	if params.Limit <= 0 {
+		params.Limit = models.DefaultListLimit
+	}
+	if params.Limit > models.MaxListLimit {
+		params.Limit = models.MaxListLimit
+	}
+	if params.SortBy == "" {
+		params.SortBy = defaultSort
+	}
+}
+
+func (s *service) listError(err error) error {
+	if err == models.ErrorBadCursor || err == models.ErrorBadSort {
+		return s.errorWorker.NewError(fasthttp.StatusBadRequest, err, err)
+	}
+	return err
+}
+
 func (s *service) Invite(request models.InviteUserRequest) (response models.InviteUserResponse, err error) {
+	if request.Role == 0 {
+		request.Role = models2.RoleDweller
+	}
+	err = s.checkAssignableRole(request.Group, request.CreatorID, int(request.Role))
+	if err != nil {
+		return
+	}
+
 	// Можно передавать смешанные списки по UserID и Email. Собираем единый.
+	// Письмо отправляется только новым пользователям: остальные увидят приглашение в сервисе.
+	letters := make(map[int]string)
 	userIds := func() map[int]bool {
 		m := make(map[int]bool)
 		for _, id := range request.UserID {
@@ -200,12 +528,7 @@ func (s *service) Invite(request models.InviteUserRequest) (response models.Invi
 			if err != nil {
 				return response, s.errorWorker.NewError(fasthttp.StatusInternalServerError, ErrorInternalServer, err)
 			}
-			go func(){
-				err := s.createInviteEmail(email, request)
-				if err != nil {
-					fmt.Println("Cannot send invite letter: ", err)
-				}
-			}()
+			letters[user.ID] = email
 		}
 
 		if _, ok := userIds[user.ID]; !ok {
@@ -214,26 +537,156 @@ func (s *service) Invite(request models.InviteUserRequest) (response models.Invi
 		}
 	}
 
-	//addedUsers := make([]string, 0, len(request.UserID))
-	addedUsersID := make([]int, 0, len(request.UserID))
-
-	for _, userId := range request.UserID {
-		err_ := s.groupStorage.InsertUser(request.Group, userId, int(request.Role))
-		if err_ != nil {
-			if err == nil {
-				err = fmt.Errorf("")
+	// Приглашения создаются целиком или не создаются вовсе: при любой ошибке транзакция откатывается.
+	invitedUsersID := make([]int, 0, len(request.UserID))
+	invitations := make([]models2.Invitation, 0, len(request.UserID))
+	err = s.inTx(func(s *service) (err error) {
+		for _, userId := range request.UserID {
+			invitation, err_ := s.inviteUser(request.Group, userId, int(request.Role), request.CreatorID)
+			if err_ != nil {
+				return s.errorWorker.NewError(fasthttp.StatusBadRequest, err_, err_)
 			}
-			err = s.errorWorker.NewError(400, errors.New("пользователь уже добавлен"), err)
-		} else {
-			addedUsersID = append(addedUsersID, userId)
+			err = s.audit(models2.AuditEntry{
+				GroupID:    request.Group,
+				ActorID:    request.CreatorID,
+				Action:     models2.AuditInvitationCreate,
+				TargetType: auditTargetInvitation,
+				TargetID:   auditID(invitation.ID),
+				RequestID:  request.RequestID,
+			}, nil, newAuditInvitation(invitation))
+			if err != nil {
+				return
+			}
+			invitations = append(invitations, invitation)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	// Письма уходят только после фиксации транзакции, чтобы не ссылаться на откаченные приглашения.
+	for _, invitation := range invitations {
+		invitedUsersID = append(invitedUsersID, invitation.UserID)
+		if email, ok := letters[invitation.UserID]; ok {
+			go s.sendInviteEmail(email, request, invitation.ID)
 		}
 	}
 	response = models.InviteUserResponse{
-		Group: request.Group, Role: request.Role, UserID: addedUsersID,
+		Group: request.Group, Role: request.Role, UserID: invitedUsersID,
+	}
+	return
+}
+
+func (s *service) inviteUser(groupID, userID, roleID, creatorID int) (invitation models2.Invitation, err error) {
+	_, err = s.groupStorage.SelectGroupRole(groupID, userID)
+	if err == nil {
+		return invitation, errors.New("пользователь уже добавлен")
+	}
+	if err != sql.ErrNoRows {
+		return
+	}
+
+	invitation, err = s.groupStorage.InsertInvitation(models2.Invitation{
+		GroupID:   groupID,
+		UserID:    userID,
+		RoleID:    roleID,
+		InvitedBy: creatorID,
+	})
+	if err == models.ErrorDuplicate {
+		return invitation, errors.New("пользователь уже приглашён")
+	}
+	return
+}
+
+func (s *service) GetUserInvitations(userID int) (invitations []models2.Invitation, err error) {
+	invitations, err = s.groupStorage.SelectInvitationsByUserID(userID)
+	return
+}
+
+func (s *service) AcceptInvitation(request models.InvitationActionRequest) (response models2.Invitation, err error) {
+	err = s.inTx(func(s *service) (err error) {
+		response, err = s.acceptInvitation(request.InvitationID, request.ActorID, request.RequestID)
+		return
+	})
+	return
+}
+
+// acceptInvitation принимает приглашение и пишет об этом в журнал. Вызывается внутри inTx.
+func (s *service) acceptInvitation(invitationID, userID int, requestID string) (invitation models2.Invitation, err error) {
+	invitation, err = s.groupStorage.AcceptInvitation(invitationID, userID)
+	if err == sql.ErrNoRows {
+		return invitation, models.ErrorNoInvitation
+	}
+	if err != nil {
+		return
+	}
+	before := newAuditInvitation(invitation)
+	before.StatusID = models2.InvitationPending
+	err = s.audit(models2.AuditEntry{
+		GroupID:    invitation.GroupID,
+		ActorID:    userID,
+		Action:     models2.AuditInvitationAccept,
+		TargetType: auditTargetInvitation,
+		TargetID:   auditID(invitation.ID),
+		RequestID:  requestID,
+	}, before, newAuditInvitation(invitation))
+	return
+}
+
+func (s *service) DeclineInvitation(request models.InvitationActionRequest) (response models2.Invitation, err error) {
+	response, err = s.groupStorage.DeclineInvitation(request.InvitationID, request.ActorID)
+	if err == sql.ErrNoRows {
+		err = models.ErrorNoInvitation
+	}
+	return
+}
+
+func (s *service) RevokeInvitation(request models.InvitationActionRequest) (response models2.Invitation, err error) {
+	response, err = s.groupStorage.RevokeInvitation(request.Group, request.InvitationID)
+	if err == sql.ErrNoRows {
+		err = models.ErrorNoInvitation
 	}
 	return
 }
 
+func (s *service) ResendInvitation(request models.ResendInvitationRequest) (response models2.Invitation, err error) {
+	invitation, err := s.groupStorage.SelectInvitation(request.InvitationID)
+	if err == sql.ErrNoRows || err == nil && (invitation.GroupID != request.Group || invitation.StatusID != models2.InvitationPending) {
+		return response, models.ErrorNoInvitation
+	}
+	if err != nil {
+		return
+	}
+
+	user, err := s.accountClient.GetUserByUid(invitation.UserID)
+	if err != nil {
+		return
+	}
+
+	err = s.sendInviteEmail(user.Email, models.InviteUserRequest{
+		CreatorID: request.ActorID,
+		RequestID: request.RequestID,
+		Group:     invitation.GroupID,
+		Role:      models2.MemberRole(invitation.RoleID),
+		Language:  request.Language,
+	}, invitation.ID)
+	if err != nil {
+		return
+	}
+
+	invitations, err := s.groupStorage.SelectInvitationsByGroupID(invitation.GroupID)
+	if err != nil {
+		return
+	}
+	for _, current := range invitations {
+		if current.ID == invitation.ID {
+			return current, nil
+		}
+	}
+	return invitation, nil
+}
+
 func (s *service) ChangeRole(request models.ChangeRoleRequest) (response models.ChangeRoleResponse, err error) {
 	if request.UserID == 0 {
 		user, err := s.accountClient.GetUserByEmail(request.User)
@@ -243,8 +696,30 @@ func (s *service) ChangeRole(request models.ChangeRoleRequest) (response models.
 		}
 		request.UserID = user.ID
 	}
-	newRole, err := s.groupStorage.EditUserRole(request.Group, request.UserID, int(request.Role))
-	response.Role = models2.MemberRole(newRole)
+	// Проверка иерархии и смена роли выполняются в одной транзакции, чтобы проверка не устарела к моменту записи.
+	err = s.inTx(func(s *service) (err error) {
+		err = s.checkHierarchy(request.Group, request.ActorID, request.UserID, int(request.Role))
+		if err != nil {
+			return
+		}
+		before, err := s.groupStorage.SelectGroupRole(request.Group, request.UserID)
+		if err != nil {
+			return
+		}
+		newRole, err := s.groupStorage.EditUserRole(request.Group, request.UserID, int(request.Role))
+		if err != nil {
+			return
+		}
+		response.Role = models2.MemberRole(newRole)
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.ActorID,
+			Action:     models2.AuditMemberRole,
+			TargetType: auditTargetUser,
+			TargetID:   auditID(request.UserID),
+			RequestID:  request.RequestID,
+		}, auditMember{RoleID: before.RoleID, RoleName: before.RoleName}, auditMember{RoleID: newRole})
+	})
 	return
 }
 
@@ -257,7 +732,31 @@ func (s *service) ExpelUser(request models.ExpelUserRequest) (response models.Ex
 		}
 		request.UserID = user.ID
 	}
-	err = s.groupStorage.RemoveUser(int(request.Group), request.UserID)
+	err = s.inTx(func(s *service) (err error) {
+		err = s.checkHierarchy(request.Group, request.ActorID, request.UserID, 0)
+		if err != nil {
+			return
+		}
+		before, err := s.groupStorage.SelectGroupRole(request.Group, request.UserID)
+		if err != nil {
+			return
+		}
+		err = s.groupStorage.RemoveUser(int(request.Group), request.UserID)
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.ActorID,
+			Action:     models2.AuditMemberExpel,
+			TargetType: auditTargetUser,
+			TargetID:   auditID(request.UserID),
+			RequestID:  request.RequestID,
+		}, auditMember{RoleID: before.RoleID, RoleName: before.RoleName}, nil)
+	})
+	if err != nil {
+		return
+	}
 	response.User = request.User
 	return
 }
@@ -282,69 +781,694 @@ func (s *service) CheckPermission(action models2.GroupAction) (err error) {
 	return
 }
 
-func (s *service) AddGroupInviteLink(request models.AddInviteLinkRequest, userID int) (response models.AddInviteLinkResponse, err error) {
-	rand.Seed(time.Now().UnixNano())
-	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz" +
-		"0123456789" +
-		"_")
-	length := 10
-	var b strings.Builder
-	for i := 0; i < length; i++ {
-		b.WriteRune(chars[rand.Intn(len(chars))])
-	}
-	line := b.String()
-	err = s.groupStorage.AddShortLinkToGroup(request.Group, line, userID)
-	if err != nil {
-		return
-	}
-	response.Group = request.Group
-	response.Link = s.getLinkFromHash(line)
-	return
+func (s *service) forbidden(err error) error {
+	return s.errorWorker.NewError(fasthttp.StatusForbidden, err, err)
 }
-func (s *service) RemoveGroupInviteLink(request models.RemoveInviteLinkRequest) (response models.RemoveInviteLinkRsponse, err error) {
-	errs := make([]string, 0)
-	removedLinks := make([]string, len(request.Links))
-	for i, item := range request.Links {
-		linkHash, _ := s.getHashFromLink(item)
-		err = s.groupStorage.RemoveLinkToGroup(request.Group, linkHash)
-		if err == nil {
-			removedLinks = append(removedLinks, item)
-		} else {
-			errs = append(errs, fmt.Sprintf("[%d]: %s", i, item))
-		}
-	}
-	if len(errs) != 0 {
-		err = fmt.Errorf(strings.Join(errs, ",\n"))
+
+func (s *service) selectRole(groupID, roleID int) (role models2.Role, err error) {
+	role, err = s.groupStorage.SelectRole(groupID, roleID)
+	if err == sql.ErrNoRows {
+		err = models.ErrorRoleNotFound
 	}
-	response.Links = removedLinks
-	response.Group = request.Group
 	return
 }
-func (s *service) ListGroupInviteLink(request models.ListInviteLinkRequest) (response models.ListInviteLinkResponse, err error) {
-	response.Links, err = s.groupStorage.ListShortLinksToGroup(request.Group)
-	for i, elem := range response.Links {
-		response.Links[i].Link = s.getLinkFromHash(elem.Link)
-		user, err := s.accountClient.GetUserByUid(elem.Author.ID)
-		if err != nil {
-			continue
+
+// memberRole возвращает роль участника группы вместе с её рангом.
+func (s *service) memberRole(groupID, userID int) (role models2.Role, err error) {
+	userRole, err := s.groupStorage.SelectGroupRole(groupID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = models.ErrorNoMembership
 		}
-		response.Links[i].Author.Login = user.Email
+		return
 	}
-	response.Group = request.Group
-	return
+
+	return s.selectRole(groupID, userRole.RoleID)
 }
 
-func (s *service) ResolveGroup(request models.ResolveInviteLinkRequest) (response models.ResolveInviteLinkResponse, err error) {
-	linkHash, _ := s.getHashFromLink(request.Link)
-	id, err := s.groupStorage.HashToGroupID(linkHash)
-	temp, err := s.groupStorage.SelectGroupByID(id)
-	response.Group = temp.ID
-	if request.UserID == 0 {
+// checkAssignableRole проверяет, что actor может выдать роль roleID: она не выше его собственной.
+func (s *service) checkAssignableRole(groupID, actorID, roleID int) (err error) {
+	actor, err := s.memberRole(groupID, actorID)
+	if err != nil {
 		return
 	}
-	err = s.groupStorage.InsertUser(response.Group, request.UserID, 3)
+
+	role, err := s.selectRole(groupID, roleID)
+	if err != nil {
+		return
+	}
+
+	if role.Rank > actor.Rank {
+		return s.forbidden(models.ErrorRoleHierarchy)
+	}
+	if !holdsActions(actor, role.Actions) {
+		return s.forbidden(models.ErrorActionNotHeld)
+	}
+	return
+}
+
+// checkHierarchy проверяет, что actor может перевести target в роль newRoleID,
+// либо исключить его из группы, если newRoleID равен 0.
+func (s *service) checkHierarchy(groupID, actorID, targetID, newRoleID int) (err error) {
+	actor, err := s.memberRole(groupID, actorID)
+	if err != nil {
+		return
+	}
+
+	target, err := s.memberRole(groupID, targetID)
+	if err != nil {
+		return
+	}
+
+	if actorID != targetID && target.Rank >= actor.Rank {
+		return s.forbidden(models.ErrorRoleHierarchy)
+	}
+
+	if newRoleID != 0 {
+		var newRole models2.Role
+		newRole, err = s.selectRole(groupID, newRoleID)
+		if err != nil {
+			return
+		}
+		if newRole.Rank > actor.Rank {
+			return s.forbidden(models.ErrorRoleHierarchy)
+		}
+		// Проверка рангов пропускает смену собственной роли, поэтому права новой роли проверяются всегда.
+		if !holdsActions(actor, newRole.Actions) {
+			return s.forbidden(models.ErrorActionNotHeld)
+		}
+		if newRole.ID == target.ID {
+			return
+		}
+	}
+
+	if target.ID != int(models2.RoleCreator) {
+		return
+	}
+
+	creators, err := s.groupStorage.CountUsersWithRole(groupID, target.ID)
+	if err != nil {
+		return
+	}
+	if creators < 2 {
+		return s.forbidden(models.ErrorLastCreator)
+	}
+	return
+}
+
+// checkRoleRank проверяет, что actor может управлять ролью ранга rank.
+func (s *service) checkRoleRank(groupID, actorID, rank int) (err error) {
+	actor, err := s.memberRole(groupID, actorID)
+	if err != nil {
+		return
+	}
+
+	if rank >= actor.Rank {
+		return s.forbidden(models.ErrorRoleRank)
+	}
+	return
+}
+
+// checkRoleActions проверяет, что actions - действия сервиса групп и actor сам может их выполнять.
+func (s *service) checkRoleActions(groupID, actorID int, actions []int) (err error) {
+	for _, action := range actions {
+		if !models2.IsGroupAction(action) {
+			return s.errorWorker.NewError(fasthttp.StatusBadRequest, models.ErrorUnknownAction, models.ErrorUnknownAction)
+		}
+	}
+
+	actor, err := s.memberRole(groupID, actorID)
+	if err != nil {
+		return
+	}
+
+	if !holdsActions(actor, actions) {
+		return s.forbidden(models.ErrorActionNotHeld)
+	}
+	return
+}
+
+func holdsActions(role models2.Role, actions []int) bool {
+	held := make(map[int]bool, len(role.Actions))
+	for _, action := range role.Actions {
+		held[action] = true
+	}
+	for _, action := range actions {
+		if !held[action] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *service) GetRoles(groupID, userID int) (roles []models2.Role, err error) {
+	roles, err = s.groupStorage.SelectRoles(groupID)
+	return
+}
+
+func (s *service) CreateRole(request models.CreateRoleRequest) (response models2.Role, err error) {
+	err = s.checkRoleRank(request.Group, request.UserID, request.Rank)
+	if err != nil {
+		return
+	}
+
+	err = s.checkRoleActions(request.Group, request.UserID, request.Actions)
+	if err != nil {
+		return
+	}
+
+	err = s.inTx(func(s *service) (err error) {
+		response, err = s.groupStorage.InsertRole(models2.Role{
+			GroupID: request.Group,
+			Title:   request.Title,
+			Rank:    request.Rank,
+			Actions: uniqueActions(request.Actions),
+		})
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.UserID,
+			Action:     models2.AuditRoleCreate,
+			TargetType: auditTargetRole,
+			TargetID:   auditID(response.ID),
+			RequestID:  request.RequestID,
+		}, nil, response)
+	})
+	return
+}
+
+func (s *service) UpdateRole(request models.UpdateRoleRequest) (response models2.Role, err error) {
+	role, err := s.checkCustomRole(request.Group, request.RoleID)
+	if err != nil {
+		return
+	}
+
+	err = s.checkRoleRank(request.Group, request.UserID, role.Rank)
+	if err != nil {
+		return
+	}
+
+	err = s.checkRoleRank(request.Group, request.UserID, request.Rank)
+	if err != nil {
+		return
+	}
+
+	err = s.checkRoleActions(request.Group, request.UserID, request.Actions)
+	if err != nil {
+		return
+	}
+
+	err = s.inTx(func(s *service) (err error) {
+		response, err = s.groupStorage.UpdateRole(models2.Role{
+			ID:      request.RoleID,
+			GroupID: request.Group,
+			Title:   request.Title,
+			Rank:    request.Rank,
+			Actions: uniqueActions(request.Actions),
+		})
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.UserID,
+			Action:     models2.AuditRoleUpdate,
+			TargetType: auditTargetRole,
+			TargetID:   auditID(request.RoleID),
+			RequestID:  request.RequestID,
+		}, role, response)
+	})
+	return
+}
+
+func (s *service) DeleteRole(request models.DeleteRoleRequest) (response models.DeleteRoleResponse, err error) {
+	role, err := s.checkCustomRole(request.Group, request.RoleID)
+	if err != nil {
+		return
+	}
+
+	err = s.checkRoleRank(request.Group, request.UserID, role.Rank)
+	if err != nil {
+		return
+	}
+
+	err = s.inTx(func(s *service) (err error) {
+		references, err := s.groupStorage.CountRoleReferences(request.Group, request.RoleID)
+		if err != nil {
+			return
+		}
+		if references > 0 {
+			return models.ErrorRoleInUse
+		}
+		err = s.groupStorage.DeleteRole(request.Group, request.RoleID)
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.UserID,
+			Action:     models2.AuditRoleDelete,
+			TargetType: auditTargetRole,
+			TargetID:   auditID(request.RoleID),
+			RequestID:  request.RequestID,
+		}, role, nil)
+	})
+	if err != nil {
+		return
+	}
+
+	response.Group = request.Group
+	response.RoleID = request.RoleID
+	return
+}
+
+func (s *service) checkCustomRole(groupID, roleID int) (role models2.Role, err error) {
+	role, err = s.selectRole(groupID, roleID)
+	if err != nil {
+		return
+	}
+
+	if role.GroupID == 0 {
+		return role, models.ErrorSystemRole
+	}
+	return
+}
+
+func uniqueActions(actions []int) (result []int) {
+	seen := make(map[int]bool, len(actions))
+	result = make([]int, 0, len(actions))
+	for _, action := range actions {
+		if action <= 0 || seen[action] {
+			continue
+		}
+		seen[action] = true
+		result = append(result, action)
+	}
+	return
+}
+
+func (s *service) ProposeTransfer(request models.ProposeTransferRequest) (response models2.OwnershipTransfer, err error) {
+	if request.UserID == 0 {
+		user, err := s.accountClient.GetUserByEmail(request.User)
+		if err != nil {
+			err = fmt.Errorf("bad user: %s", err)
+			return response, err
+		}
+		request.UserID = user.ID
+	}
+
+	if request.UserID == request.ActorID {
+		return response, models.ErrorSelfTransfer
+	}
+
+	actor, err := s.memberRole(request.Group, request.ActorID)
+	if err != nil {
+		return
+	}
+	if actor.ID != int(models2.RoleCreator) {
+		return response, s.forbidden(models.ErrorNotCreator)
+	}
+
+	_, err = s.memberRole(request.Group, request.UserID)
+	if err != nil {
+		return
+	}
+
+	response, err = s.groupStorage.InsertTransfer(models2.OwnershipTransfer{
+		GroupID:    request.Group,
+		FromUserID: request.ActorID,
+		ToUserID:   request.UserID,
+		ExpiresAt:  time.Now().Add(time.Duration(internal.Config.OwnershipTransferTTL) * time.Hour),
+	})
+	return
+}
+
+func (s *service) GetTransferList(userID int) (response []models2.OwnershipTransfer, err error) {
+	response, err = s.groupStorage.SelectTransfersByUserID(userID)
+	return
+}
+
+func (s *service) AcceptTransfer(request models.TransferActionRequest) (response models2.OwnershipTransfer, err error) {
+	response, err = s.selectTransfer(request.TransferID)
+	if err != nil {
+		return
+	}
+
+	if response.ToUserID != request.ActorID {
+		return response, models.ErrorNoTransfer
+	}
+
+	err = s.inTx(func(s *service) (err error) {
+		err = s.groupStorage.AcceptTransfer(response, int(models2.RoleCreator), int(models2.RoleAdmin))
+		if err == sql.ErrNoRows {
+			return models.ErrorNoTransfer
+		}
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    response.GroupID,
+			ActorID:    request.ActorID,
+			Action:     models2.AuditGroupTransfer,
+			TargetType: auditTargetGroup,
+			TargetID:   auditID(response.GroupID),
+			RequestID:  request.RequestID,
+		}, auditOwner{CreatorID: response.FromUserID}, auditOwner{CreatorID: response.ToUserID})
+	})
+	if err == models.ErrorStaleTransfer {
+		// Устаревший запрос принять уже нельзя, поэтому он отменяется и пропадает из списков.
+		if cancelErr := s.groupStorage.CancelTransfer(response.ID); cancelErr != nil && cancelErr != sql.ErrNoRows {
+			s.log.Error().Str("msg", "cannot cancel stale transfer").Int("transfer", response.ID).Err(cancelErr).Send()
+		}
+		return response, s.errorWorker.NewError(fasthttp.StatusConflict, models.ErrorStaleTransfer, err)
+	}
+	return
+}
+
+func (s *service) CancelTransfer(request models.TransferActionRequest) (response models2.OwnershipTransfer, err error) {
+	response, err = s.selectTransfer(request.TransferID)
+	if err != nil {
+		return
+	}
+
+	if response.FromUserID != request.ActorID && response.ToUserID != request.ActorID {
+		return response, models.ErrorNoTransfer
+	}
+
+	err = s.groupStorage.CancelTransfer(request.TransferID)
+	if err == sql.ErrNoRows {
+		err = models.ErrorNoTransfer
+	}
+	return
+}
+
+func (s *service) selectTransfer(transferID int) (transfer models2.OwnershipTransfer, err error) {
+	transfer, err = s.groupStorage.SelectTransfer(transferID)
+	if err == sql.ErrNoRows {
+		err = models.ErrorNoTransfer
+	}
+	return
+}
+
+func (s *service) AddGroupInviteLink(request models.AddInviteLinkRequest, userID int) (response models.AddInviteLinkResponse, err error) {
+	if request.Role == 0 {
+		request.Role = models2.RoleDweller
+	}
+	err = s.checkAssignableRole(request.Group, userID, int(request.Role))
+	if err != nil {
+		return
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return response, models.ErrorBadExpiration
+	}
+
+	var line string
+	for attempt := 0; attempt < maxLinkInsertAttempts; attempt++ {
+		line, err = s.tokenGenerator.Generate()
+		if err != nil {
+			return
+		}
+
+		link := models2.GroupInviteLink{
+			Link:      line,
+			Author:    models2.AuthorPack{ID: userID},
+			RoleID:    int(request.Role),
+			ExpiresAt: request.ExpiresAt,
+			MaxUses:   request.MaxUses,
+		}
+		// Каждая попытка - отдельная транзакция: после ошибки уникальности транзакция в PostgreSQL уже прервана.
+		err = s.inTx(func(s *service) (err error) {
+			err = s.groupStorage.AddShortLinkToGroup(request.Group, link)
+			if err != nil {
+				return
+			}
+			return s.audit(models2.AuditEntry{
+				GroupID:    request.Group,
+				ActorID:    userID,
+				Action:     models2.AuditLinkCreate,
+				TargetType: auditTargetLink,
+				TargetID:   line,
+				RequestID:  request.RequestID,
+			}, nil, newAuditLink(link))
+		})
+		if err != models.ErrorDuplicate {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	response.Group = request.Group
+	response.Link = s.getLinkFromHash(line)
+	return
+}
+func (s *service) RemoveGroupInviteLink(request models.RemoveInviteLinkRequest) (response models.RemoveInviteLinkRsponse, err error) {
+	errs := make([]string, 0)
+	removedLinks := make([]string, len(request.Links))
+	for i, item := range request.Links {
+		linkHash, _ := s.getHashFromLink(item)
+		err = s.removeInviteLink(request, linkHash)
+		if err == nil {
+			removedLinks = append(removedLinks, item)
+		} else {
+			errs = append(errs, fmt.Sprintf("[%d]: %s", i, item))
+		}
+	}
+	if len(errs) != 0 {
+		err = fmt.Errorf(strings.Join(errs, ",\n"))
+	}
+	response.Links = removedLinks
+	response.Group = request.Group
+	return
+}
+
+func (s *service) removeInviteLink(request models.RemoveInviteLinkRequest, linkHash string) (err error) {
+	return s.inTx(func(s *service) (err error) {
+		before, err := s.groupStorage.SelectLinkByHash(linkHash)
+		if err != nil {
+			return
+		}
+		err = s.groupStorage.RemoveLinkToGroup(request.Group, linkHash)
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.ActorID,
+			Action:     models2.AuditLinkRemove,
+			TargetType: auditTargetLink,
+			TargetID:   linkHash,
+			RequestID:  request.RequestID,
+		}, newAuditLink(before), nil)
+	})
+}
+
+func (s *service) ListGroupInviteLink(request models.ListInviteLinkRequest) (response models.ListInviteLinkResponse, err error) {
+	response.Links, err = s.groupStorage.ListShortLinksToGroup(request.Group)
+	authorIDs := make([]int, 0, len(response.Links))
+	for _, elem := range response.Links {
+		authorIDs = append(authorIDs, elem.Author.ID)
+	}
+	users := s.newUserCache()
+	users.prefetch(authorIDs)
+
+	for i, elem := range response.Links {
+		response.Links[i].Link = s.getLinkFromHash(elem.Link)
+		if elem.MaxUses > 0 {
+			remaining := elem.MaxUses - elem.Uses
+			if remaining < 0 {
+				remaining = 0
+			}
+			response.Links[i].RemainingUses = &remaining
+		}
+		user, ok := users.get(elem.Author.ID)
+		if !ok {
+			continue
+		}
+		response.Links[i].Author.Login = user.Email
+	}
+	response.Group = request.Group
+	return
+}
+
+func (s *service) ResolveGroup(request models.ResolveInviteLinkRequest) (response models.ResolveInviteLinkResponse, err error) {
+	linkHash, _ := s.getHashFromLink(request.Link)
+	link, err := s.selectValidLink(linkHash)
+	if err != nil {
+		return
+	}
+
+	temp, err := s.groupStorage.SelectGroupByID(link.GroupID)
+	if err != nil {
+		return
+	}
+	response.Group = temp.ID
+	if request.UserID == 0 {
+		return
+	}
+
+	invitation, err := s.groupStorage.SelectInvitationByLink(linkHash)
+	if err == nil {
+		err = s.acceptLinkInvitation(invitation, linkHash, request.UserID, request.RequestID)
+		if err == nil {
+			response.UserID = request.UserID
+		}
+		return
+	}
+	if err != sql.ErrNoRows {
+		return
+	}
+
+	if temp.JoinApproval {
+		_, err = s.groupStorage.SelectGroupRole(temp.ID, request.UserID)
+		if err == nil {
+			return response, models.ErrorAlreadyMember
+		}
+		if err != sql.ErrNoRows {
+			return
+		}
+	}
+
+	// Использование ссылки засчитывается только вместе с вступлением или заявкой на него.
+	err = s.inTx(func(s *service) (err error) {
+		err = s.groupStorage.UseLink(linkHash)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err = models.ErrorLinkExhausted
+			}
+			return
+		}
+
+		if temp.JoinApproval {
+			_, err = s.groupStorage.InsertJoinRequest(temp.ID, request.UserID)
+			return
+		}
+		return s.groupStorage.InsertUser(temp.ID, request.UserID, link.RoleID)
+	})
+	// Повторная заявка не ошибка: ссылка при этом не расходуется.
+	if temp.JoinApproval && err == models.ErrorDuplicate {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
 	response.UserID = request.UserID
+	response.Pending = temp.JoinApproval
+	return
+}
+
+// acceptLinkInvitation принимает приглашение по ссылке из письма: ссылка одноразовая и действует только для приглашённого,
+// а заявка на вступление не нужна, потому что пригласил участник с правами.
+func (s *service) acceptLinkInvitation(invitation models2.Invitation, linkHash string, userID int, requestID string) (err error) {
+	if invitation.UserID != userID {
+		return models.ErrorNotInvitee
+	}
+	if invitation.StatusID != models2.InvitationPending {
+		return models.ErrorNoInvitation
+	}
+
+	return s.inTx(func(s *service) (err error) {
+		err = s.groupStorage.UseLink(linkHash)
+		if err == sql.ErrNoRows {
+			return models.ErrorLinkExhausted
+		}
+		if err != nil {
+			return
+		}
+		_, err = s.acceptInvitation(invitation.ID, userID, requestID)
+		return
+	})
+}
+
+func (s *service) GetJoinRequests(groupID, userID int) (requests []models2.JoinRequest, err error) {
+	requests, err = s.groupStorage.SelectJoinRequests(groupID)
+	if err != nil {
+		return
+	}
+
+	userIDs := make([]int, 0, len(requests))
+	for _, request := range requests {
+		userIDs = append(userIDs, request.UserID)
+	}
+	users := s.newUserCache()
+	users.prefetch(userIDs)
+
+	for i := range requests {
+		user, ok := users.get(requests[i].UserID)
+		if !ok {
+			continue
+		}
+		requests[i].Email = user.Email
+		requests[i].Name = user.Name
+		requests[i].Surname = user.Surname
+	}
+	return
+}
+
+func (s *service) GetUserJoinRequests(userID int) (requests []models2.JoinRequest, err error) {
+	requests, err = s.groupStorage.SelectJoinRequestsByUserID(userID)
+	return
+}
+
+func (s *service) ApproveJoinRequest(request models.JoinRequestDecisionRequest) (response models2.JoinRequest, err error) {
+	group, err := s.groupStorage.SelectGroupByID(request.Group)
+	if err != nil {
+		return
+	}
+
+	roleID := group.JoinRoleID
+	if roleID == 0 {
+		roleID = int(models2.RoleDweller)
+	}
+
+	err = s.inTx(func(s *service) (err error) {
+		response, err = s.groupStorage.ApproveJoinRequest(request.Group, request.RequestID, roleID, request.ActorID)
+		if err == sql.ErrNoRows {
+			return models.ErrorNoJoinRequest
+		}
+		if err != nil {
+			return
+		}
+		return s.audit(models2.AuditEntry{
+			GroupID:    request.Group,
+			ActorID:    request.ActorID,
+			Action:     models2.AuditMemberJoin,
+			TargetType: auditTargetUser,
+			TargetID:   auditID(response.UserID),
+			RequestID:  request.TraceID,
+		}, nil, auditMember{RoleID: roleID})
+	})
+	return
+}
+
+func (s *service) RejectJoinRequest(request models.JoinRequestDecisionRequest) (response models2.JoinRequest, err error) {
+	response, err = s.groupStorage.RejectJoinRequest(request.Group, request.RequestID, request.ActorID)
+	if err == sql.ErrNoRows {
+		err = models.ErrorNoJoinRequest
+	}
+	return
+}
+
+func (s *service) selectValidLink(linkHash string) (link models2.GroupInviteLink, err error) {
+	link, err = s.groupStorage.SelectLinkByHash(linkHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = models.ErrorLinkNotFound
+		}
+		return
+	}
+
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return link, models.ErrorLinkExpired
+	}
+
+	if link.MaxUses > 0 && link.Uses >= link.MaxUses {
+		return link, models.ErrorLinkExhausted
+	}
 	return
 }
 
@@ -354,36 +1478,115 @@ func (s *service) GetUserRole(groupID, userID int) (role models2.UserRole, err e
 	return
 }
 
-func (s *service) GetMembershipList(groupID, userID int) (memberships []models2.Membership, err error) {
-	//TODO CHECK PERMISSION
-
+func (s *service) GetMembershipList(request models.MembershipListRequest) (memberships []models2.Membership, page models.Page, err error) {
 	memberships = make([]models2.Membership, 0)
-	usersRoles, err := s.groupStorage.SelectUsersByGroupID(groupID)
+	normalizeListParams(&request.ListParams, models.SortByJoined)
+	usersRoles, page, err := s.groupStorage.SelectUsersByGroupID(request.Group, request.ListParams)
 	if err != nil {
+		err = s.listError(err)
 		return
 	}
 
-	//TODO REWORK TO ARRAY REQUEST
-	for i, _ := range usersRoles {
-		var tempUser models3.User
-		tempUser, err = s.accountClient.GetUserByUid(usersRoles[i].UserID)
+	// Приглашённые не участвуют в постраничной выдаче и добавляются к первой странице.
+	invitations := make([]models2.Invitation, 0)
+	if request.WithPending && request.Cursor == "" {
+		invitations, err = s.groupStorage.SelectInvitationsByGroupID(request.Group)
 		if err != nil {
 			return
 		}
+	}
+
+	userIDs := make([]int, 0, len(usersRoles)+len(invitations))
+	for _, userRole := range usersRoles {
+		userIDs = append(userIDs, userRole.UserID)
+	}
+	for _, invitation := range invitations {
+		userIDs = append(userIDs, invitation.UserID)
+	}
+	users := s.newUserCache()
+	users.prefetch(userIDs)
+
+	// Если пользователя не удалось получить из сервиса аккаунтов, он остаётся в списке без имени и почты.
+	for _, userRole := range usersRoles {
+		user, _ := users.get(userRole.UserID)
+		memberships = append(memberships, models2.Membership{
+			UserID:    userRole.UserID,
+			GroupID:   userRole.GroupID,
+			RoleID:    userRole.RoleID,
+			RoleName:  userRole.RoleName,
+			Email:     user.Email,
+			Name:      user.Name,
+			Surname:   user.Surname,
+			AvatarURL: user.AvatarURL,
+			JoinedAt:  userRole.JoinedAt,
+		})
+	}
+
+	for _, invitation := range invitations {
+		user, _ := users.get(invitation.UserID)
+		memberships = append(memberships, models2.Membership{
+			UserID:       invitation.UserID,
+			GroupID:      invitation.GroupID,
+			RoleID:       invitation.RoleID,
+			RoleName:     invitation.RoleName,
+			Email:        user.Email,
+			Name:         user.Name,
+			Surname:      user.Surname,
+			AvatarURL:    user.AvatarURL,
+			Pending:      true,
+			InvitationID: invitation.ID,
+			Delivery:     invitation.Delivery,
+		})
+	}
+	return
+}
+
+// userCache хранит пользователей, полученных из сервиса аккаунтов, в пределах одного запроса к сервису.
+type userCache struct {
+	accountClient accountClient
+	log           *zerolog.Logger
+	users         map[int]models3.User
+	missing       map[int]bool
+}
+
+func (s *service) newUserCache() *userCache {
+	return &userCache{
+		accountClient: s.accountClient,
+		log:           s.log,
+		users:         make(map[int]models3.User),
+		missing:       make(map[int]bool),
+	}
+}
 
-		tempMembership := models2.Membership{
-			UserID:    usersRoles[i].UserID,
-			GroupID:   usersRoles[i].GroupID,
-			RoleID:    usersRoles[i].RoleID,
-			RoleName:  usersRoles[i].RoleName,
-			Email:     tempUser.Email,
-			Name:      tempUser.Name,
-			Surname:   tempUser.Surname,
-			AvatarURL: tempUser.AvatarURL,
+// prefetch одним пакетным запросом получает всех ещё не загруженных пользователей.
+func (c *userCache) prefetch(userIDs []int) {
+	wanted := make([]int, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if _, ok := c.users[userID]; !ok && !c.missing[userID] {
+			wanted = append(wanted, userID)
 		}
+	}
+	if len(wanted) == 0 {
+		return
+	}
 
-		memberships = append(memberships, tempMembership)
+	// Списки отдаются и без сведений о пользователях, но недоступность сервиса аккаунтов должна быть видна в журнале.
+	users, err := c.accountClient.GetUsersByUids(wanted)
+	if err != nil {
+		c.log.Error().Str("msg", "cannot get users from account service").Ints("users", wanted).Err(err).Send()
+	}
+	for _, userID := range wanted {
+		if user, ok := users[userID]; ok {
+			c.users[userID] = user
+		} else {
+			c.missing[userID] = true
+		}
 	}
+}
+
+func (c *userCache) get(userID int) (user models3.User, ok bool) {
+	c.prefetch([]int{userID})
+	user, ok = c.users[userID]
 	return
 }
 
@@ -402,7 +1605,23 @@ func (s *service) getLinkFromHash(src string) string {
 	return fmt.Sprintf("%s/%s", internal.Config.InviteLinkPrefix, src)
 }
 
-func (s *service) createInviteEmail(email string, request models.InviteUserRequest) (err error) {
+// sendInviteEmail ставит письмо с приглашением в очередь. Ошибка пишется в журнал и в статус доставки приглашения,
+// чтобы администратор увидел её в списке приглашений и отправил письмо повторно.
+func (s *service) sendInviteEmail(email string, request models.InviteUserRequest, invitationID int) (err error) {
+	err = s.createInviteEmail(email, request, invitationID)
+	if err == nil {
+		return
+	}
+	s.log.Error().Str("msg", "cannot queue invite letter").Int("invitation", invitationID).Str("to", email).Err(err).Send()
+	if err := s.groupStorage.SetInvitationDeliveryError(invitationID, err.Error()); err != nil {
+		s.log.Error().Str("msg", "cannot record invite letter failure").Int("invitation", invitationID).Err(err).Send()
+	}
+	return
+}
+
+// createInviteEmail ставит письмо с новой одноразовой ссылкой в очередь и привязывает его к приглашению,
+// чтобы администратор видел статус доставки. Ссылка из предыдущего письма отзывается.
+func (s *service) createInviteEmail(email string, request models.InviteUserRequest, invitationID int) (err error) {
 	admin, err := s.accountClient.GetUserByUid(request.CreatorID)
 	if err != nil {
 		return err
@@ -411,10 +1630,68 @@ func (s *service) createInviteEmail(email string, request models.InviteUserReque
 	if err != nil {
 		return err
 	}
-	addLinkResp, err := s.AddGroupInviteLink(models.AddInviteLinkRequest{Group:request.Group}, request.CreatorID)
+	addLinkResp, err := s.AddGroupInviteLink(models.AddInviteLinkRequest{
+		RequestID: request.RequestID,
+		Group:     request.Group,
+		Role:      request.Role,
+		MaxUses:   1,
+	}, request.CreatorID)
 	if err != nil {
 		return err
 	}
-	err = sendInviteMessage(email, admin.Name, admin.Surname, admin.Email, admin.AvatarURL, group.Title, addLinkResp.Link)
+	linkHash, _ := s.getHashFromLink(addLinkResp.Link)
+	remove := models.RemoveInviteLinkRequest{ActorID: request.CreatorID, RequestID: request.RequestID, Group: request.Group}
+
+	message, err := composeInviteMessage(s.templates, request.Language, email, admin.Name, admin.Surname, admin.Email,
+		admin.AvatarURL, group.Title, addLinkResp.Link)
+	var outboxMessage models.OutboxMessage
+	if err == nil {
+		outboxMessage, err = s.outbox.InsertMessage(models.OutboxMessage{
+			From:    internal.Config.InviteLetterSender,
+			To:      email,
+			Message: message,
+		})
+	}
+	// Ссылку никто не получил, поэтому она удаляется.
+	if err != nil {
+		if err := s.removeInviteLink(remove, linkHash); err != nil {
+			s.log.Error().Str("msg", "cannot remove unsent invite link").Str("link", linkHash).Err(err).Send()
+		}
+		return
+	}
+
+	return s.inTx(func(s *service) (err error) {
+		previousLink, err := s.groupStorage.SetInvitationMessage(invitationID, outboxMessage.ID, linkHash)
+		if err != nil || previousLink == "" {
+			return
+		}
+		// Ссылка из прошлого письма могла быть уже использована или удалена.
+		err = s.removeInviteLink(remove, previousLink)
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return
+	})
+}
+
+func (s *service) PreviewTemplate(request models.TemplatePreviewRequest) (response models.TemplatePreviewResponse, err error) {
+	vars := sampleInviteTemplateVars()
+
+	switch request.Template {
+	case inviteTemplateMessage:
+		response.ContentType = "message/rfc822"
+		response.Body, err = renderInviteMessage(s.templates, request.Locale, "invitee@example.com", vars, nil)
+	case inviteTemplateHTML:
+		response.ContentType = "text/html; charset=utf-8"
+		response.Body, err = s.templates.Execute(request.Locale, request.Template, vars)
+	case inviteTemplateText, inviteTemplateSubject:
+		response.ContentType = "text/plain; charset=utf-8"
+		response.Body, err = s.templates.Execute(request.Locale, request.Template, vars)
+	default:
+		return response, s.errorWorker.NewError(fasthttp.StatusNotFound, models.ErrorNoTemplate, models.ErrorNoTemplate)
+	}
+	if err != nil {
+		return response, s.errorWorker.NewError(fasthttp.StatusInternalServerError, err, err)
+	}
 	return
-}
\ No newline at end of file
+}
@@ -0,0 +1,431 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	account "github.com/Solar-2020/Account-Backend/pkg/models"
+	httpErrors "github.com/Solar-2020/GoUtils/http/errorWorker"
+	"github.com/Solar-2020/Group-Backend/internal"
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	storage "github.com/Solar-2020/Group-Backend/internal/storages/groupStorage"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+const (
+	testCreatorID = 1
+	testAdminID   = 2
+	testDwellerID = 3
+)
+
+// fakeAccounts знает любого пользователя: его почта строится из ID.
+type fakeAccounts struct{}
+
+func (fakeAccounts) GetUserByUid(userID int) (user account.User, err error) {
+	return account.User{ID: userID, Email: fmt.Sprintf("user%d@mail.ru", userID)}, nil
+}
+
+func (fakeAccounts) GetUserByEmail(email string) (user account.User, err error) {
+	return user, errors.New("not found")
+}
+
+func (fakeAccounts) CreateUserAdvance(request account.UserAdvance) (user account.User, err error) {
+	return user, errors.New("not implemented")
+}
+
+func (a fakeAccounts) GetUsersByUids(userIDs []int) (users map[int]account.User, err error) {
+	users = make(map[int]account.User, len(userIDs))
+	for _, userID := range userIDs {
+		users[userID], _ = a.GetUserByUid(userID)
+	}
+	return
+}
+
+type counterTokens struct {
+	last int
+}
+
+func (g *counterTokens) Generate() (token string, err error) {
+	g.last++
+	return fmt.Sprintf("token%d", g.last), nil
+}
+
+// fakeOutbox запоминает письма или, если задана err, отказывает в постановке в очередь.
+type fakeOutbox struct {
+	messages []models.OutboxMessage
+	err      error
+}
+
+func (o *fakeOutbox) InsertMessage(message models.OutboxMessage) (messageReturn models.OutboxMessage, err error) {
+	if o.err != nil {
+		return message, o.err
+	}
+	message.ID = len(o.messages) + 1
+	o.messages = append(o.messages, message)
+	return message, nil
+}
+
+// fakeTemplates подставляет в каждую часть письма только ссылку приглашения.
+type fakeTemplates struct{}
+
+func (fakeTemplates) Execute(locale, name string, vars interface{}) (result []byte, err error) {
+	return []byte(name + " " + vars.(inviteTemplateVars).InviteLink), nil
+}
+
+func (fakeTemplates) Locales() (locales []string) {
+	return []string{"ru"}
+}
+
+type serviceFixture struct {
+	service *service
+	storage storage.Storage
+	outbox  *fakeOutbox
+	groupID int
+}
+
+// newServiceFixture создаёт группу с создателем, администратором и участником в хранилище в памяти.
+func newServiceFixture(t *testing.T) serviceFixture {
+	internal.Config.InviteLinkPrefix = "https://host/welcome"
+	internal.Config.OwnershipTransferTTL = 72
+	memory := storage.NewMemoryStorage()
+	outbox := &fakeOutbox{}
+	log := zerolog.New(ioutil.Discard)
+	s := NewService(memory, fakeAccounts{}, httpErrors.NewErrorWorker(), &counterTokens{}, outbox, fakeTemplates{},
+		&log).(*service)
+
+	group, err := s.Create(models2.Group{Title: "Группа", URL: "team", CreateBy: testCreatorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = memory.InsertUser(group.ID, testAdminID, int(models2.RoleAdmin)); err != nil {
+		t.Fatal(err)
+	}
+	if err = memory.InsertUser(group.ID, testDwellerID, int(models2.RoleDweller)); err != nil {
+		t.Fatal(err)
+	}
+	return serviceFixture{service: s, storage: memory, outbox: outbox, groupID: group.ID}
+}
+
+func TestCreateRoleRejectsForeignActions(t *testing.T) {
+	f := newServiceFixture(t)
+
+	cases := []struct {
+		name    string
+		actions []int
+	}{
+		{"not held", []int{models2.ActionViewGroup, models2.ActionDeleteGroup}},
+		{"unknown", []int{models2.ActionViewGroup, 42}},
+	}
+	for _, c := range cases {
+		_, err := f.service.CreateRole(models.CreateRoleRequest{
+			UserID:  testAdminID,
+			Group:   f.groupID,
+			Title:   c.name,
+			Rank:    199,
+			Actions: c.actions,
+		})
+		if err == nil {
+			t.Errorf("%s: role created", c.name)
+		}
+	}
+
+	roles, err := f.storage.SelectRoles(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range roles {
+		if role.GroupID != 0 {
+			t.Errorf("custom role %q persisted", role.Title)
+		}
+	}
+}
+
+func TestChangeRoleRejectsSelfEscalation(t *testing.T) {
+	f := newServiceFixture(t)
+
+	// Роль с правом удаления группы, которого у администратора нет, может создать только создатель.
+	role, err := f.service.CreateRole(models.CreateRoleRequest{
+		UserID:  testCreatorID,
+		Group:   f.groupID,
+		Title:   "Удаляющий",
+		Rank:    150,
+		Actions: []int{models2.ActionViewGroup, models2.ActionDeleteGroup},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.service.ChangeRole(models.ChangeRoleRequest{
+		ActorID: testAdminID,
+		UserID:  testAdminID,
+		Group:   f.groupID,
+		Role:    models2.MemberRole(role.ID),
+	})
+	if err == nil {
+		t.Fatal("admin assigned a role with actions they do not hold")
+	}
+
+	userRole, err := f.storage.SelectGroupRole(f.groupID, testAdminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userRole.RoleID != int(models2.RoleAdmin) {
+		t.Errorf("admin role changed to %d", userRole.RoleID)
+	}
+}
+
+func TestDeleteRoleRejectsReferencedRole(t *testing.T) {
+	f := newServiceFixture(t)
+
+	newRole := func(title string) models2.Role {
+		role, err := f.service.CreateRole(models.CreateRoleRequest{
+			UserID:  testCreatorID,
+			Group:   f.groupID,
+			Title:   title,
+			Rank:    50,
+			Actions: []int{models2.ActionViewGroup},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return role
+	}
+
+	linkRole := newRole("По ссылке")
+	err := f.storage.AddShortLinkToGroup(f.groupID, models2.GroupInviteLink{
+		Link:   "rolelink",
+		Author: models2.AuthorPack{ID: testCreatorID},
+		RoleID: linkRole.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invitationRole := newRole("По приглашению")
+	_, err = f.storage.InsertInvitation(models2.Invitation{
+		GroupID:   f.groupID,
+		UserID:    42,
+		RoleID:    invitationRole.ID,
+		InvitedBy: testCreatorID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joinRole := newRole("При вступлении")
+	group, err := f.storage.SelectGroupByID(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.JoinRoleID = joinRole.ID
+	if _, err = f.storage.UpdateGroup(group); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range []models2.Role{linkRole, invitationRole, joinRole} {
+		_, err = f.service.DeleteRole(models.DeleteRoleRequest{UserID: testCreatorID, Group: f.groupID, RoleID: role.ID})
+		if err != models.ErrorRoleInUse {
+			t.Errorf("%s: got %v, want %v", role.Title, err, models.ErrorRoleInUse)
+		}
+		if _, err = f.storage.SelectRole(f.groupID, role.ID); err != nil {
+			t.Errorf("%s: role deleted: %v", role.Title, err)
+		}
+	}
+}
+
+func TestResendInvitationRevokesPreviousLink(t *testing.T) {
+	f := newServiceFixture(t)
+	invitation, err := f.storage.InsertInvitation(models2.Invitation{
+		GroupID:   f.groupID,
+		UserID:    42,
+		RoleID:    int(models2.RoleDweller),
+		InvitedBy: testCreatorID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resend := models.ResendInvitationRequest{ActorID: testCreatorID, Group: f.groupID, InvitationID: invitation.ID}
+	for i := 0; i < 2; i++ {
+		if _, err = f.service.ResendInvitation(resend); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links, err := f.storage.ListShortLinksToGroup(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].Link != "token2" {
+		t.Errorf("links after resend: %+v", links)
+	}
+	if len(f.outbox.messages) != 2 {
+		t.Errorf("%d letters queued, want 2", len(f.outbox.messages))
+	}
+}
+
+func TestInviteEmailFailureIsRecorded(t *testing.T) {
+	f := newServiceFixture(t)
+	invitation, err := f.storage.InsertInvitation(models2.Invitation{
+		GroupID:   f.groupID,
+		UserID:    42,
+		RoleID:    int(models2.RoleDweller),
+		InvitedBy: testCreatorID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.outbox.err = errors.New("outbox is down")
+
+	_, err = f.service.ResendInvitation(models.ResendInvitationRequest{ActorID: testCreatorID, Group: f.groupID,
+		InvitationID: invitation.ID})
+	if err != f.outbox.err {
+		t.Fatalf("got %v, want %v", err, f.outbox.err)
+	}
+
+	invitations, err := f.storage.SelectInvitationsByGroupID(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 || invitations[0].Delivery == nil || invitations[0].Delivery.StatusID != models2.DeliveryFailed {
+		t.Fatalf("invitations %+v", invitations)
+	}
+	if invitations[0].Delivery.LastError != f.outbox.err.Error() {
+		t.Errorf("last error %q", invitations[0].Delivery.LastError)
+	}
+
+	links, err := f.storage.ListShortLinksToGroup(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 0 {
+		t.Errorf("unsent link kept: %+v", links)
+	}
+}
+
+func TestInviteLetterLinkAcceptsInvitation(t *testing.T) {
+	f := newServiceFixture(t)
+	invitation, err := f.storage.InsertInvitation(models2.Invitation{
+		GroupID:   f.groupID,
+		UserID:    42,
+		RoleID:    int(models2.RoleDweller),
+		InvitedBy: testCreatorID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.service.ResendInvitation(models.ResendInvitationRequest{ActorID: testCreatorID, Group: f.groupID,
+		InvitationID: invitation.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := f.service.getLinkFromHash("token1")
+
+	if _, err = f.service.ResolveGroup(models.ResolveInviteLinkRequest{Link: link, UserID: 43}); err != models.ErrorNotInvitee {
+		t.Errorf("another user: got %v, want %v", err, models.ErrorNotInvitee)
+	}
+
+	response, err := f.service.ResolveGroup(models.ResolveInviteLinkRequest{Link: link, UserID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Group != f.groupID || response.UserID != 42 || response.Pending {
+		t.Errorf("response %+v", response)
+	}
+	if _, err = f.storage.SelectGroupRole(f.groupID, 42); err != nil {
+		t.Errorf("invitee is not a member: %v", err)
+	}
+	stored, err := f.storage.SelectInvitation(invitation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.StatusID != models2.InvitationAccepted {
+		t.Errorf("invitation status %d, want %d", stored.StatusID, models2.InvitationAccepted)
+	}
+}
+
+func TestAcceptTransferRejectsStaleTransfer(t *testing.T) {
+	cases := []struct {
+		name  string
+		stale func(f serviceFixture) error
+	}{
+		{"group deleted", func(f serviceFixture) error {
+			_, err := f.storage.UpdateGroupStatus(f.groupID, models2.GroupDeleted)
+			return err
+		}},
+		{"proposer demoted", func(f serviceFixture) error {
+			// Второй создатель нужен, чтобы первого можно было понизить.
+			if _, err := f.storage.EditUserRole(f.groupID, testDwellerID, int(models2.RoleCreator)); err != nil {
+				return err
+			}
+			_, err := f.storage.EditUserRole(f.groupID, testCreatorID, int(models2.RoleAdmin))
+			return err
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newServiceFixture(t)
+			transfer, err := f.service.ProposeTransfer(models.ProposeTransferRequest{ActorID: testCreatorID, Group: f.groupID,
+				UserID: testAdminID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = c.stale(f); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = f.service.AcceptTransfer(models.TransferActionRequest{ActorID: testAdminID, TransferID: transfer.ID})
+			if err == nil || err.Error() != models.ErrorStaleTransfer.Error() {
+				t.Fatalf("got %v, want %v", err, models.ErrorStaleTransfer)
+			}
+			role, err := f.storage.SelectGroupRole(f.groupID, testAdminID)
+			if err != nil || role.RoleID != int(models2.RoleAdmin) {
+				t.Errorf("admin role %+v, %v", role, err)
+			}
+			if transfers, _ := f.storage.SelectTransfersByUserID(testAdminID); len(transfers) != 0 {
+				t.Errorf("stale transfer is still pending: %+v", transfers)
+			}
+		})
+	}
+}
+
+func TestGetPreviewHidesUnlistedGroupByID(t *testing.T) {
+	f := newServiceFixture(t)
+	group, err := f.storage.SelectGroupByID(f.groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.VisibilityID = models2.VisibilityUnlisted
+	if _, err = f.storage.UpdateGroup(group); err != nil {
+		t.Fatal(err)
+	}
+	link := models2.GroupInviteLink{Link: "preview", Author: models2.AuthorPack{ID: testCreatorID}, RoleID: int(models2.RoleDweller)}
+	if err = f.storage.AddShortLinkToGroup(f.groupID, link); err != nil {
+		t.Fatal(err)
+	}
+
+	const outsiderID = 42
+	cases := []struct {
+		name    string
+		request models.GetGroupRequest
+		visible bool
+	}{
+		{"id", models.GetGroupRequest{UserID: outsiderID, Group: f.groupID}, false},
+		{"slug", models.GetGroupRequest{UserID: outsiderID, Slug: group.URL}, true},
+		{"invite link", models.GetGroupRequest{UserID: outsiderID, Group: f.groupID, Link: "https://host/welcome/preview"}, true},
+		{"foreign invite link", models.GetGroupRequest{UserID: outsiderID, Group: f.groupID + 1, Link: "preview"}, false},
+		{"member by id", models.GetGroupRequest{UserID: testDwellerID, Group: f.groupID}, true},
+	}
+	for _, c := range cases {
+		preview, err := f.service.GetPreview(c.request)
+		if c.visible && (err != nil || preview.ID != f.groupID) {
+			t.Errorf("%s: got %+v, %v", c.name, preview, err)
+		}
+		if !c.visible && err == nil {
+			t.Errorf("%s: unlisted group previewed", c.name)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/Solar-2020/GoUtils/http"
 	"github.com/Solar-2020/Group-Backend/internal/models"
+	"github.com/Solar-2020/Group-Backend/internal/services/email"
 	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
 	"github.com/go-playground/validator"
 	"github.com/valyala/fasthttp"
@@ -15,20 +16,27 @@ type Transport interface {
 	CreateDecode(ctx *fasthttp.RequestCtx) (request models2.Group, err error)
 	CreateEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error)
 
-	UpdateDecode(ctx *fasthttp.RequestCtx) (request models2.Group, userID int, err error)
+	UpdateDecode(ctx *fasthttp.RequestCtx) (request models2.Group, userID int, requestID string, err error)
 	UpdateEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error)
 
-	DeleteDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, err error)
+	DeleteDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, requestID string, err error)
 	DeleteEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error)
 
-	GetDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, err error)
+	RestoreDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, requestID string, err error)
+	GetDeletedListDecode(ctx *fasthttp.RequestCtx) (userID int, err error)
+
+	GetDecode(ctx *fasthttp.RequestCtx) (request models.GetGroupRequest, err error)
 	GetEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error)
 
-	GetListDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error)
-	GetListEncode(response []models2.GroupPreview, ctx *fasthttp.RequestCtx) (err error)
+	CheckSlugDecode(ctx *fasthttp.RequestCtx) (slug string, err error)
+	DiscoverDecode(ctx *fasthttp.RequestCtx) (request models.DiscoverRequest, err error)
+	SearchGroupsDecode(ctx *fasthttp.RequestCtx) (request models.SearchGroupsRequest, err error)
+
+	GetListDecode(ctx *fasthttp.RequestCtx) (request models.GroupListRequest, err error)
+	GetListEncode(response []models2.GroupPreview, page models.Page, ctx *fasthttp.RequestCtx) (err error)
 
-	InternalGetListDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error)
-	InternalGetListEncode(response []models2.GroupPreview, ctx *fasthttp.RequestCtx) (err error)
+	InternalGetListDecode(ctx *fasthttp.RequestCtx) (request models.GroupListRequest, err error)
+	InternalGetListEncode(response []models2.GroupPreview, page models.Page, ctx *fasthttp.RequestCtx) (err error)
 
 	InternalGetPermissionDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error)
 	InternalGetPermissionEncode(response models2.UserRole, ctx *fasthttp.RequestCtx) (err error)
@@ -38,6 +46,9 @@ type Transport interface {
 
 	InviteDecode(ctx *fasthttp.RequestCtx) (request models.InviteUserRequest, err error)
 
+	PreviewTemplateDecode(ctx *fasthttp.RequestCtx) (request models.TemplatePreviewRequest, err error)
+	PreviewTemplateEncode(response models.TemplatePreviewResponse, ctx *fasthttp.RequestCtx) (err error)
+
 	ChangeRoleDecode(ctx *fasthttp.RequestCtx) (request models.ChangeRoleRequest, err error)
 	ExpelDecode(ctx *fasthttp.RequestCtx) (request models.ExpelUserRequest, err error)
 
@@ -46,8 +57,28 @@ type Transport interface {
 	RemoveLinkDecode(ctx *fasthttp.RequestCtx) (request models.RemoveInviteLinkRequest, err error)
 	ListLinkDecode(ctx *fasthttp.RequestCtx) (request models.ListInviteLinkRequest, err error)
 
-	GetMembershipListDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error)
-	GetMembershipListEncode(response []models2.Membership, ctx *fasthttp.RequestCtx) (err error)
+	GetMembershipListDecode(ctx *fasthttp.RequestCtx) (request models.MembershipListRequest, err error)
+	GetMembershipListEncode(response []models2.Membership, page models.Page, ctx *fasthttp.RequestCtx) (err error)
+
+	GetRolesDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error)
+	CreateRoleDecode(ctx *fasthttp.RequestCtx) (request models.CreateRoleRequest, err error)
+	UpdateRoleDecode(ctx *fasthttp.RequestCtx) (request models.UpdateRoleRequest, err error)
+	DeleteRoleDecode(ctx *fasthttp.RequestCtx) (request models.DeleteRoleRequest, err error)
+
+	ProposeTransferDecode(ctx *fasthttp.RequestCtx) (request models.ProposeTransferRequest, err error)
+	GetTransferListDecode(ctx *fasthttp.RequestCtx) (userID int, err error)
+	TransferActionDecode(ctx *fasthttp.RequestCtx) (request models.TransferActionRequest, err error)
+
+	GetJoinRequestsDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error)
+	GetUserJoinRequestsDecode(ctx *fasthttp.RequestCtx) (userID int, err error)
+	JoinRequestDecisionDecode(ctx *fasthttp.RequestCtx) (request models.JoinRequestDecisionRequest, err error)
+
+	GetUserInvitationsDecode(ctx *fasthttp.RequestCtx) (userID int, err error)
+	InvitationActionDecode(ctx *fasthttp.RequestCtx) (request models.InvitationActionRequest, err error)
+	ResendInvitationDecode(ctx *fasthttp.RequestCtx) (request models.ResendInvitationRequest, err error)
+
+	AuditDecode(ctx *fasthttp.RequestCtx) (request models.AuditListRequest, err error)
+	AuditEncode(response []models2.AuditEntry, page models.Page, ctx *fasthttp.RequestCtx) (err error)
 }
 
 type transport struct {
@@ -93,7 +124,7 @@ func (t transport) CreateEncode(response models2.Group, ctx *fasthttp.RequestCtx
 	return
 }
 
-func (t transport) UpdateDecode(ctx *fasthttp.RequestCtx) (request models2.Group, userID int, err error) {
+func (t transport) UpdateDecode(ctx *fasthttp.RequestCtx) (request models2.Group, userID int, requestID string, err error) {
 	var group models2.Group
 	var ok bool
 	err = json.Unmarshal(ctx.Request.Body(), &group)
@@ -112,13 +143,14 @@ func (t transport) UpdateDecode(ctx *fasthttp.RequestCtx) (request models2.Group
 		return
 	}
 
+	requestID = requestIDFromCtx(ctx)
 	userID, ok = ctx.UserValue("userID").(int)
 	if ok {
 		request = group
 		return
 	}
 
-	return request, userID, errors.New("userID not found")
+	return request, userID, requestID, errors.New("userID not found")
 }
 
 func (t transport) UpdateEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error) {
@@ -132,7 +164,7 @@ func (t transport) UpdateEncode(response models2.Group, ctx *fasthttp.RequestCtx
 	return
 }
 
-func (t transport) DeleteDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, err error) {
+func (t transport) DeleteDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, requestID string, err error) {
 	var ok bool
 	groupIDStr := ctx.UserValue("groupID").(string)
 	groupID, err = strconv.Atoi(groupIDStr)
@@ -140,12 +172,13 @@ func (t transport) DeleteDecode(ctx *fasthttp.RequestCtx) (groupID, userID int,
 		return
 	}
 
+	requestID = requestIDFromCtx(ctx)
 	userID, ok = ctx.UserValue("userID").(int)
 	if ok {
 		return
 	}
 
-	return groupID, userID, errors.New("userID not found")
+	return groupID, userID, requestID, errors.New("userID not found")
 }
 
 func (t transport) DeleteEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error) {
@@ -159,20 +192,88 @@ func (t transport) DeleteEncode(response models2.Group, ctx *fasthttp.RequestCtx
 	return
 }
 
-func (t transport) GetDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, err error) {
+func (t transport) RestoreDecode(ctx *fasthttp.RequestCtx) (groupID, userID int, requestID string, err error) {
+	return t.DeleteDecode(ctx)
+}
+
+func (t transport) GetDeletedListDecode(ctx *fasthttp.RequestCtx) (userID int, err error) {
+	var ok bool
+	userID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return userID, errors.New("userID not found")
+}
+
+func (t transport) GetDecode(ctx *fasthttp.RequestCtx) (request models.GetGroupRequest, err error) {
 	var ok bool
 	groupIDStr := ctx.UserValue("groupID").(string)
-	groupID, err = strconv.Atoi(groupIDStr)
+	request.Group, err = strconv.Atoi(groupIDStr)
 	if err != nil {
+		request.Slug, err = groupIDStr, nil
+	}
+	request.Link = string(ctx.QueryArgs().Peek("link"))
+
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
 		return
 	}
 
-	userID, ok = ctx.UserValue("userID").(int)
+	return request, errors.New("userID not found")
+}
+
+func (t transport) DiscoverDecode(ctx *fasthttp.RequestCtx) (request models.DiscoverRequest, err error) {
+	var ok bool
+	if limit := ctx.QueryArgs().Peek("limit"); limit != nil {
+		request.Limit, err = strconv.Atoi(string(limit))
+		if err != nil {
+			return
+		}
+	}
+	request.Cursor = string(ctx.QueryArgs().Peek("cursor"))
+
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) SearchGroupsDecode(ctx *fasthttp.RequestCtx) (request models.SearchGroupsRequest, err error) {
+	var ok bool
+	request.Query = string(ctx.QueryArgs().Peek("q"))
+	request.Scope = string(ctx.QueryArgs().Peek("scope"))
+	if limit := ctx.QueryArgs().Peek("limit"); limit != nil {
+		request.Limit, err = strconv.Atoi(string(limit))
+		if err != nil {
+			return
+		}
+	}
+	if offset := ctx.QueryArgs().Peek("offset"); offset != nil {
+		request.Offset, err = strconv.Atoi(string(offset))
+		if err != nil {
+			return
+		}
+	}
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.UserID, ok = ctx.UserValue("userID").(int)
 	if ok {
 		return
 	}
 
-	return groupID, userID, errors.New("userID not found")
+	return request, errors.New("userID not found")
+}
+
+func (t transport) CheckSlugDecode(ctx *fasthttp.RequestCtx) (slug string, err error) {
+	slug, err = http.GetUrlParamString(ctx, "slug")
+	return
 }
 
 func (t transport) GetEncode(response models2.Group, ctx *fasthttp.RequestCtx) (err error) {
@@ -186,51 +287,111 @@ func (t transport) GetEncode(response models2.Group, ctx *fasthttp.RequestCtx) (
 	return
 }
 
-func (t transport) GetListDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error) {
+func (t transport) GetListDecode(ctx *fasthttp.RequestCtx) (request models.GroupListRequest, err error) {
 	var ok bool
 	_group := ctx.QueryArgs().Peek("group_id")
 	if _group != nil {
-		groupID, _ = strconv.Atoi(string(_group))
+		request.Group, _ = strconv.Atoi(string(_group))
 	}
 
-	userID, ok = ctx.UserValue("userID").(int)
+	request.ListParams, err = t.decodeListParams(ctx)
+	if err != nil {
+		return
+	}
+
+	request.UserID, ok = ctx.UserValue("userID").(int)
 	if ok {
 		return
 	}
 
-	return groupID, userID, errors.New("userID not found")
+	return request, errors.New("userID not found")
 }
 
-func (t transport) GetListEncode(response []models2.GroupPreview, ctx *fasthttp.RequestCtx) (err error) {
+var errorGroupMismatch = errors.New("Группа в запросе не совпадает с проверенной")
+
+// checkedGroupID возвращает группу, права в которой проверил middleware CheckPermission. На маршрутах без проверки
+// прав возвращается группа из запроса.
+func checkedGroupID(ctx *fasthttp.RequestCtx, requested int) (groupID int, err error) {
+	groupID, ok := ctx.UserValue("checkedGroupID").(int)
+	if !ok {
+		return requested, nil
+	}
+	if requested != 0 && requested != groupID {
+		return 0, errorGroupMismatch
+	}
+	return groupID, nil
+}
+
+// requestIDFromCtx возвращает идентификатор запроса, выданный middleware Log.
+func requestIDFromCtx(ctx *fasthttp.RequestCtx) string {
+	requestID, _ := ctx.UserValue("requestID").(string)
+	return requestID
+}
+
+// decodeListParams читает limit, cursor, sort, order (asc/desc) и role из query.
+func (t transport) decodeListParams(ctx *fasthttp.RequestCtx) (params models.ListParams, err error) {
+	args := ctx.QueryArgs()
+	if limit := args.Peek("limit"); limit != nil {
+		params.Limit, err = strconv.Atoi(string(limit))
+		if err != nil {
+			return
+		}
+	}
+	if role := args.Peek("role"); role != nil {
+		params.RoleID, err = strconv.Atoi(string(role))
+		if err != nil {
+			return
+		}
+	}
+	params.Cursor = string(args.Peek("cursor"))
+	params.SortBy = string(args.Peek("sort"))
+	params.Desc = string(args.Peek("order")) == "desc"
+
+	err = t.validator.Struct(params)
+	return
+}
+
+// setPageHeaders отдаёт общее число записей и курсор следующей страницы, не меняя формат тела ответа.
+func setPageHeaders(page models.Page, ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		ctx.Response.Header.Set("X-Next-Cursor", page.NextCursor)
+	}
+}
+
+func (t transport) GetListEncode(response []models2.GroupPreview, page models.Page, ctx *fasthttp.RequestCtx) (err error) {
 	body, err := json.Marshal(response)
 	if err != nil {
 		return
 	}
+	setPageHeaders(page, ctx)
 	ctx.Response.Header.SetContentType("application/json")
 	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetBody(body)
 	return
 }
 
-func (t transport) InternalGetListDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error) {
+func (t transport) InternalGetListDecode(ctx *fasthttp.RequestCtx) (request models.GroupListRequest, err error) {
 	_group := ctx.QueryArgs().Peek("group_id")
 	if _group != nil {
-		groupID, _ = strconv.Atoi(string(_group))
+		request.Group, _ = strconv.Atoi(string(_group))
 	}
 
 	_userID := ctx.QueryArgs().Peek("user_id")
 	if _userID != nil {
-		userID, _ = strconv.Atoi(string(_userID))
+		request.UserID, _ = strconv.Atoi(string(_userID))
 	}
 
+	request.ListParams, err = t.decodeListParams(ctx)
 	return
 }
 
-func (t transport) InternalGetListEncode(response []models2.GroupPreview, ctx *fasthttp.RequestCtx) (err error) {
+func (t transport) InternalGetListEncode(response []models2.GroupPreview, page models.Page, ctx *fasthttp.RequestCtx) (err error) {
 	body, err := json.Marshal(response)
 	if err != nil {
 		return
 	}
+	setPageHeaders(page, ctx)
 	ctx.Response.Header.SetContentType("application/json")
 	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetBody(body)
@@ -312,6 +473,14 @@ func (t transport) InviteDecode(ctx *fasthttp.RequestCtx) (request models.Invite
 			request.Group = urlId
 		}
 	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
+	if request.Language == "" {
+		request.Language = email.ParseAcceptLanguage(string(ctx.Request.Header.Peek("Accept-Language")))
+	}
+	request.RequestID = requestIDFromCtx(ctx)
 	err = t.validator.Struct(request)
 	//request.CreatorID =280
 	request.CreatorID, ok = ctx.UserValue("userID").(int)
@@ -322,22 +491,66 @@ func (t transport) InviteDecode(ctx *fasthttp.RequestCtx) (request models.Invite
 	return request, errors.New("userID not found")
 }
 
+func (t transport) PreviewTemplateDecode(ctx *fasthttp.RequestCtx) (request models.TemplatePreviewRequest, err error) {
+	request.Template = string(ctx.QueryArgs().Peek("template"))
+	request.Locale = string(ctx.QueryArgs().Peek("locale"))
+	err = t.validator.Struct(request)
+	return
+}
+
+func (t transport) PreviewTemplateEncode(response models.TemplatePreviewResponse, ctx *fasthttp.RequestCtx) (err error) {
+	ctx.Response.Header.SetContentType(response.ContentType)
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(response.Body)
+	return
+}
+
 func (t transport) ChangeRoleDecode(ctx *fasthttp.RequestCtx) (request models.ChangeRoleRequest, err error) {
+	var ok bool
 	err = json.Unmarshal(ctx.Request.Body(), &request)
 	if err != nil {
 		return
 	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
 	err = t.validator.Struct(request)
-	return
+	if err != nil {
+		return
+	}
+
+	request.RequestID = requestIDFromCtx(ctx)
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
 }
 
 func (t transport) ExpelDecode(ctx *fasthttp.RequestCtx) (request models.ExpelUserRequest, err error) {
+	var ok bool
 	err = json.Unmarshal(ctx.Request.Body(), &request)
 	if err != nil {
 		return
 	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
 	err = t.validator.Struct(request)
-	return
+	if err != nil {
+		return
+	}
+
+	request.RequestID = requestIDFromCtx(ctx)
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
 }
 
 func (t transport) ResolveDecode(ctx *fasthttp.RequestCtx) (request models.ResolveInviteLinkRequest, err error) {
@@ -356,6 +569,7 @@ func (t transport) ResolveDecode(ctx *fasthttp.RequestCtx) (request models.Resol
 	if tempInt := ctx.UserValue("userID"); tempInt != nil {
 		request.UserID = tempInt.(int)
 	}
+	request.RequestID = requestIDFromCtx(ctx)
 	return
 }
 
@@ -372,7 +586,12 @@ func (t transport) AddLinkDecode(ctx *fasthttp.RequestCtx) (request models.AddIn
 			request.Group = urlId
 		}
 	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
 	err = t.validator.Struct(request)
+	request.RequestID = requestIDFromCtx(ctx)
 	var ok bool
 	userID, ok = ctx.UserValue("userID").(int)
 	if ok {
@@ -382,12 +601,27 @@ func (t transport) AddLinkDecode(ctx *fasthttp.RequestCtx) (request models.AddIn
 }
 
 func (t transport) RemoveLinkDecode(ctx *fasthttp.RequestCtx) (request models.RemoveInviteLinkRequest, err error) {
+	var ok bool
 	err = json.Unmarshal(ctx.Request.Body(), &request)
 	if err != nil {
 		return
 	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
 	err = t.validator.Struct(request)
-	return
+	if err != nil {
+		return
+	}
+
+	request.RequestID = requestIDFromCtx(ctx)
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
 }
 
 func (t transport) ListLinkDecode(ctx *fasthttp.RequestCtx) (request models.ListInviteLinkRequest, err error) {
@@ -395,6 +629,10 @@ func (t transport) ListLinkDecode(ctx *fasthttp.RequestCtx) (request models.List
 	if _group != nil {
 		request.Group, _ = strconv.Atoi(string(_group))
 	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
 
 	var ok bool
 	request.UserID, ok = ctx.UserValue("userID").(int)
@@ -409,15 +647,46 @@ func (t transport) ListLinkDecode(ctx *fasthttp.RequestCtx) (request models.List
 	return
 }
 
-func (t transport) GetMembershipListDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error) {
+func (t transport) GetMembershipListDecode(ctx *fasthttp.RequestCtx) (request models.MembershipListRequest, err error) {
 	var ok bool
-	if groupID == 0 {
-		if urlId, err := http.GetUrlParamInt(ctx, "groupID"); err == nil {
-			groupID = urlId
-		}
+	if urlId, err := http.GetUrlParamInt(ctx, "groupID"); err == nil {
+		request.Group = urlId
+	}
+
+	request.WithPending = ctx.QueryArgs().GetBool("pending")
+
+	request.ListParams, err = t.decodeListParams(ctx)
+	if err != nil {
+		return
+	}
+
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
 	}
-	//userID = 12
-	//return
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) GetMembershipListEncode(response []models2.Membership, page models.Page, ctx *fasthttp.RequestCtx) (err error) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	setPageHeaders(page, ctx)
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBody(body)
+	return
+}
+
+func (t transport) GetRolesDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error) {
+	var ok bool
+	groupID, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
 	userID, ok = ctx.UserValue("userID").(int)
 	if ok {
 		return
@@ -426,11 +695,297 @@ func (t transport) GetMembershipListDecode(ctx *fasthttp.RequestCtx) (userID, gr
 	return userID, groupID, errors.New("userID not found")
 }
 
-func (t transport) GetMembershipListEncode(response []models2.Membership, ctx *fasthttp.RequestCtx) (err error) {
+func (t transport) CreateRoleDecode(ctx *fasthttp.RequestCtx) (request models.CreateRoleRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.RequestID = requestIDFromCtx(ctx)
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) UpdateRoleDecode(ctx *fasthttp.RequestCtx) (request models.UpdateRoleRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.RequestID = requestIDFromCtx(ctx)
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) DeleteRoleDecode(ctx *fasthttp.RequestCtx) (request models.DeleteRoleRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.RequestID = requestIDFromCtx(ctx)
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) ProposeTransferDecode(ctx *fasthttp.RequestCtx) (request models.ProposeTransferRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) GetTransferListDecode(ctx *fasthttp.RequestCtx) (userID int, err error) {
+	var ok bool
+	userID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return userID, errors.New("userID not found")
+}
+
+func (t transport) TransferActionDecode(ctx *fasthttp.RequestCtx) (request models.TransferActionRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+	request.RequestID = requestIDFromCtx(ctx)
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) GetJoinRequestsDecode(ctx *fasthttp.RequestCtx) (userID, groupID int, err error) {
+	var ok bool
+	groupID, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
+	userID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return userID, groupID, errors.New("userID not found")
+}
+
+func (t transport) GetUserJoinRequestsDecode(ctx *fasthttp.RequestCtx) (userID int, err error) {
+	var ok bool
+	userID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return userID, errors.New("userID not found")
+}
+
+func (t transport) JoinRequestDecisionDecode(ctx *fasthttp.RequestCtx) (request models.JoinRequestDecisionRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+	request.TraceID = requestIDFromCtx(ctx)
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) GetUserInvitationsDecode(ctx *fasthttp.RequestCtx) (userID int, err error) {
+	var ok bool
+	userID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return userID, errors.New("userID not found")
+}
+
+func (t transport) ResendInvitationDecode(ctx *fasthttp.RequestCtx) (request models.ResendInvitationRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+	request.RequestID = requestIDFromCtx(ctx)
+	if request.Language == "" {
+		request.Language = email.ParseAcceptLanguage(string(ctx.Request.Header.Peek("Accept-Language")))
+	}
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) InvitationActionDecode(ctx *fasthttp.RequestCtx) (request models.InvitationActionRequest, err error) {
+	var ok bool
+	err = json.Unmarshal(ctx.Request.Body(), &request)
+	if err != nil {
+		return
+	}
+
+	if urlId, err := http.GetUrlParamInt(ctx, "groupID"); err == nil {
+		request.Group = urlId
+	}
+	request.Group, err = checkedGroupID(ctx, request.Group)
+	if err != nil {
+		return
+	}
+	request.RequestID = requestIDFromCtx(ctx)
+
+	err = t.validator.Struct(request)
+	if err != nil {
+		return
+	}
+
+	request.ActorID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) AuditDecode(ctx *fasthttp.RequestCtx) (request models.AuditListRequest, err error) {
+	var ok bool
+	request.Group, err = http.GetUrlParamInt(ctx, "groupID")
+	if err != nil {
+		return
+	}
+
+	args := ctx.QueryArgs()
+	if actor := args.Peek("actor"); actor != nil {
+		request.ActorID, err = strconv.Atoi(string(actor))
+		if err != nil {
+			return
+		}
+	}
+	request.Action = string(args.Peek("action"))
+
+	if limit := args.Peek("limit"); limit != nil {
+		request.Limit, err = strconv.Atoi(string(limit))
+		if err != nil {
+			return
+		}
+	}
+	request.Cursor = string(args.Peek("cursor"))
+
+	request.UserID, ok = ctx.UserValue("userID").(int)
+	if ok {
+		return
+	}
+
+	return request, errors.New("userID not found")
+}
+
+func (t transport) AuditEncode(response []models2.AuditEntry, page models.Page, ctx *fasthttp.RequestCtx) (err error) {
 	body, err := json.Marshal(response)
 	if err != nil {
 		return
 	}
+	setPageHeaders(page, ctx)
 	ctx.Response.Header.SetContentType("application/json")
 	ctx.Response.Header.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetBody(body)
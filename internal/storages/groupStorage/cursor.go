@@ -0,0 +1,69 @@
+package groupStorage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/Solar-2020/Group-Backend/internal/models"
+)
+
+// listCursor - позиция в постраничном списке: значение ключа сортировки и ID последней отданной записи.
+// Клиенту отдаётся в base64 и для него непрозрачна.
+type listCursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d"`
+	Key    string `json:"k"`
+	ID     int    `json:"i"`
+}
+
+func encodeCursor(cursor listCursor) string {
+	body, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func decodeCursor(value string, params models.ListParams) (cursor listCursor, err error) {
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, models.ErrorBadCursor
+	}
+	err = json.Unmarshal(body, &cursor)
+	if err != nil || cursor.SortBy != params.SortBy || cursor.Desc != params.Desc {
+		return cursor, models.ErrorBadCursor
+	}
+	return
+}
+
+// sortKey - выражение, по которому сортируется список, и тип для приведения значения из курсора.
+type sortKey struct {
+	expr string
+	cast string
+}
+
+// appendKeyset дописывает к запросу условие "после курсора", сортировку и LIMIT. Выбирается на одну
+// запись больше limit, чтобы понять, есть ли следующая страница.
+func appendKeyset(query string, params []interface{}, key sortKey, idExpr string, listParams models.ListParams) (string, []interface{}, error) {
+	compare, order := ">", "ASC"
+	if listParams.Desc {
+		compare, order = "<", "DESC"
+	}
+
+	if listParams.Cursor != "" {
+		cursor, err := decodeCursor(listParams.Cursor, listParams)
+		if err != nil {
+			return "", nil, err
+		}
+		query += fmt.Sprintf(` AND (%s, %s) %s ($%d::%s, $%d)`, key.expr, idExpr, compare, len(params)+1, key.cast, len(params)+2)
+		params = append(params, cursor.Key, cursor.ID)
+	}
+
+	query += fmt.Sprintf(`
+	ORDER BY %s %s, %s %s
+	LIMIT $%d`, key.expr, order, idExpr, order, len(params)+1)
+	params = append(params, listParams.Limit+1)
+	return query, params, nil
+}
+
+// countQuery оборачивает запрос без курсора и сортировки в подсчёт строк для заголовка X-Total-Count.
+func countQuery(query string) string {
+	return `SELECT count(*) FROM (` + query + `) AS counted`
+}
@@ -0,0 +1,1393 @@
+package groupStorage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// firstCustomRoleID совпадает с началом последовательности roles.id после системных ролей в миграциях.
+const firstCustomRoleID = 101
+
+type memberKey struct {
+	groupID int
+	userID  int
+}
+
+type memoryMember struct {
+	roleID   int
+	joinedAt time.Time
+}
+
+type memoryTransfer struct {
+	models2.OwnershipTransfer
+	statusID int
+}
+
+type memoryInvitation struct {
+	models2.Invitation
+	messageID     int
+	link          string
+	deliveryError string
+}
+
+// memoryData - содержимое таблиц. Значения в картах не изменяются на месте, поэтому для отката
+// транзакции достаточно копии самих карт.
+type memoryData struct {
+	groups       map[int]models2.Group
+	roles        map[int]models2.Role
+	members      map[memberKey]memoryMember
+	links        map[string]models2.GroupInviteLink
+	transfers    map[int]memoryTransfer
+	joinRequests map[int]models2.JoinRequest
+	invitations  map[int]memoryInvitation
+	audit        map[int]models2.AuditEntry
+	lastID       map[string]int
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		groups:       make(map[int]models2.Group, len(d.groups)),
+		roles:        make(map[int]models2.Role, len(d.roles)),
+		members:      make(map[memberKey]memoryMember, len(d.members)),
+		links:        make(map[string]models2.GroupInviteLink, len(d.links)),
+		transfers:    make(map[int]memoryTransfer, len(d.transfers)),
+		joinRequests: make(map[int]models2.JoinRequest, len(d.joinRequests)),
+		invitations:  make(map[int]memoryInvitation, len(d.invitations)),
+		audit:        make(map[int]models2.AuditEntry, len(d.audit)),
+		lastID:       make(map[string]int, len(d.lastID)),
+	}
+	for k, v := range d.groups {
+		c.groups[k] = v
+	}
+	for k, v := range d.roles {
+		c.roles[k] = v
+	}
+	for k, v := range d.members {
+		c.members[k] = v
+	}
+	for k, v := range d.links {
+		c.links[k] = v
+	}
+	for k, v := range d.transfers {
+		c.transfers[k] = v
+	}
+	for k, v := range d.joinRequests {
+		c.joinRequests[k] = v
+	}
+	for k, v := range d.invitations {
+		c.invitations[k] = v
+	}
+	for k, v := range d.audit {
+		c.audit[k] = v
+	}
+	for k, v := range d.lastID {
+		c.lastID[k] = v
+	}
+	return c
+}
+
+func (d *memoryData) nextID(table string) int {
+	d.lastID[table]++
+	return d.lastID[table]
+}
+
+type memoryStorage struct {
+	mu   *sync.Mutex
+	data **memoryData
+	// inTx - хранилище выдано внутри WithTx, блокировка уже захвачена.
+	inTx bool
+}
+
+// NewMemoryStorage создаёт хранилище в памяти с той же семантикой, что и Postgres: системные роли,
+// ошибки уникальности и фильтры по статусам. Письма в нём не хранятся, поэтому Invitation.Delivery заполняется
+// только для писем, которые не удалось поставить в очередь.
+func NewMemoryStorage() Storage {
+	data := &memoryData{
+		groups:       make(map[int]models2.Group),
+		roles:        make(map[int]models2.Role),
+		members:      make(map[memberKey]memoryMember),
+		links:        make(map[string]models2.GroupInviteLink),
+		transfers:    make(map[int]memoryTransfer),
+		joinRequests: make(map[int]models2.JoinRequest),
+		invitations:  make(map[int]memoryInvitation),
+		audit:        make(map[int]models2.AuditEntry),
+		lastID:       map[string]int{rolesTable: firstCustomRoleID - 1},
+	}
+	data.roles[int(models2.RoleCreator)] = models2.Role{ID: int(models2.RoleCreator), Title: "Создатель", Rank: 300,
+		Actions: []int{101, 102, 103, 104, 105, 106, 107, 108, 109, 110, 111}}
+	data.roles[int(models2.RoleAdmin)] = models2.Role{ID: int(models2.RoleAdmin), Title: "Администратор", Rank: 200,
+		Actions: []int{101, 102, 104, 105, 106, 107, 108, 109, 110, 111}}
+	data.roles[int(models2.RoleDweller)] = models2.Role{ID: int(models2.RoleDweller), Title: "Участник", Rank: 100,
+		Actions: []int{101, 104}}
+
+	return &memoryStorage{
+		mu:   &sync.Mutex{},
+		data: &data,
+	}
+}
+
+func (s *memoryStorage) lock() *memoryData {
+	if !s.inTx {
+		s.mu.Lock()
+	}
+	return *s.data
+}
+
+func (s *memoryStorage) unlock() {
+	if !s.inTx {
+		s.mu.Unlock()
+	}
+}
+
+// WithTx держит блокировку всё время выполнения fn, так что транзакции выполняются последовательно.
+// При ошибке или панике восстанавливается копия данных, снятая перед началом.
+func (s *memoryStorage) WithTx(fn func(tx Storage) error) (err error) {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := (*s.data).clone()
+	committed := false
+	defer func() {
+		if !committed {
+			*s.data = snapshot
+		}
+	}()
+
+	err = fn(&memoryStorage{mu: s.mu, data: s.data, inTx: true})
+	committed = err == nil
+	return
+}
+
+// atomic выполняет многошаговый метод целиком или никак, как транзакция в Postgres.
+func (s *memoryStorage) atomic(fn func(d *memoryData) error) error {
+	return s.WithTx(func(tx Storage) error {
+		return fn(*tx.(*memoryStorage).data)
+	})
+}
+
+func (s *memoryStorage) InsertGroup(group models2.Group) (groupReturn models2.Group, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	if d.slugTaken(group.URL, 0) {
+		return group, models.ErrorDuplicate
+	}
+	group.ID = d.nextID("groups")
+	group.CreatAt = time.Now()
+	group.StatusID = 1
+	group.Count = 0
+	group.UserRole = models2.UserRole{}
+	d.groups[group.ID] = group
+	return group, nil
+}
+
+func (d *memoryData) slugTaken(slug string, exceptID int) bool {
+	if slug == "" {
+		return false
+	}
+	for _, group := range d.groups {
+		if group.URL == slug && group.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStorage) UpdateGroup(group models2.Group) (groupReturn models2.Group, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.groups[group.ID]
+	if !ok {
+		return group, sql.ErrNoRows
+	}
+	if d.slugTaken(group.URL, group.ID) {
+		return group, models.ErrorDuplicate
+	}
+	stored.Title = group.Title
+	stored.Description = group.Description
+	stored.URL = group.URL
+	stored.AvatarURL = group.AvatarURL
+	stored.JoinApproval = group.JoinApproval
+	stored.JoinRoleID = group.JoinRoleID
+	stored.VisibilityID = group.VisibilityID
+	d.groups[group.ID] = stored
+
+	group.Title, group.Description, group.URL, group.CreateBy = stored.Title, stored.Description, stored.URL, stored.CreateBy
+	group.CreatAt, group.StatusID, group.AvatarURL = stored.CreatAt, stored.StatusID, stored.AvatarURL
+	group.JoinApproval, group.JoinRoleID, group.VisibilityID = stored.JoinApproval, stored.JoinRoleID, stored.VisibilityID
+	return group, nil
+}
+
+func (s *memoryStorage) UpdateGroupStatus(groupID, statusID int) (group models2.Group, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	group, ok := d.groups[groupID]
+	if !ok {
+		return models2.Group{}, sql.ErrNoRows
+	}
+	group.StatusID = statusID
+	group.DeletedAt = nil
+	if statusID == models2.GroupDeleted {
+		now := time.Now()
+		group.DeletedAt = &now
+	}
+	d.groups[groupID] = group
+	return group, nil
+}
+
+func (s *memoryStorage) SelectDeletedGroups(userID, roleID int, deletedAfter time.Time) (groups []models2.DeletedGroup, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	groups = make([]models2.DeletedGroup, 0)
+	for key, member := range d.members {
+		group := d.groups[key.groupID]
+		if key.userID != userID || member.roleID != roleID || group.StatusID != models2.GroupDeleted ||
+			group.DeletedAt == nil || !group.DeletedAt.After(deletedAfter) {
+			continue
+		}
+		groups = append(groups, models2.DeletedGroup{
+			ID:        group.ID,
+			Title:     group.Title,
+			URL:       group.URL,
+			AvatarURL: group.AvatarURL,
+			Count:     d.countMembers(group.ID),
+			DeletedAt: *group.DeletedAt,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if !groups[i].DeletedAt.Equal(groups[j].DeletedAt) {
+			return groups[i].DeletedAt.After(groups[j].DeletedAt)
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return
+}
+
+func (s *memoryStorage) PurgeDeletedGroups(deletedBefore time.Time, limit int) (groupIDs []int, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	groupIDs = make([]int, 0)
+	for _, group := range d.groups {
+		if group.StatusID == models2.GroupDeleted && group.DeletedAt != nil && !group.DeletedAt.After(deletedBefore) {
+			groupIDs = append(groupIDs, group.ID)
+		}
+	}
+	sort.Slice(groupIDs, func(i, j int) bool {
+		return d.groups[groupIDs[i]].DeletedAt.Before(*d.groups[groupIDs[j]].DeletedAt)
+	})
+	if len(groupIDs) > limit {
+		groupIDs = groupIDs[:limit]
+	}
+
+	purged := make(map[int]bool, len(groupIDs))
+	for _, groupID := range groupIDs {
+		purged[groupID] = true
+		delete(d.groups, groupID)
+	}
+	for key := range d.members {
+		if purged[key.groupID] {
+			delete(d.members, key)
+		}
+	}
+	for line, link := range d.links {
+		if purged[link.GroupID] {
+			delete(d.links, line)
+		}
+	}
+	for id, role := range d.roles {
+		if purged[role.GroupID] {
+			delete(d.roles, id)
+		}
+	}
+	for id, transfer := range d.transfers {
+		if purged[transfer.GroupID] {
+			delete(d.transfers, id)
+		}
+	}
+	for id, request := range d.joinRequests {
+		if purged[request.GroupID] {
+			delete(d.joinRequests, id)
+		}
+	}
+	for id, invitation := range d.invitations {
+		if purged[invitation.GroupID] {
+			delete(d.invitations, id)
+		}
+	}
+	return
+}
+
+func (s *memoryStorage) SelectGroupByID(groupID int) (group models2.Group, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	group, ok := d.groups[groupID]
+	if !ok || group.StatusID != 1 {
+		return models2.Group{}, sql.ErrNoRows
+	}
+	group.Count = d.countMembers(groupID)
+	return group, nil
+}
+
+func (d *memoryData) countMembers(groupID int) (count int) {
+	for key := range d.members {
+		if key.groupID == groupID {
+			count++
+		}
+	}
+	return
+}
+
+func (s *memoryStorage) SelectGroupIDBySlug(slug string) (groupID int, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for _, group := range d.groups {
+		if group.URL == slug {
+			return group.ID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *memoryStorage) SelectGroupRole(groupID, userID int) (role models2.UserRole, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	role.UserID = userID
+	role.GroupID = groupID
+	member, ok := d.members[memberKey{groupID, userID}]
+	if !ok {
+		return role, sql.ErrNoRows
+	}
+	role.RoleID = member.roleID
+	role.RoleName = d.roles[member.roleID].Title
+	return role, nil
+}
+
+func (s *memoryStorage) SelectPermission(actionID, roleID int) (permission models.Permission, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for _, action := range d.roles[roleID].Actions {
+		if action == actionID {
+			return models.Permission{ActionID: actionID, RoleID: roleID}, nil
+		}
+	}
+	return permission, sql.ErrNoRows
+}
+
+// listKey - значения, по которым сортируются постраничные списки, как в memberSortKeys и groupSortKeys.
+type listKey struct {
+	title   string
+	joined  time.Time
+	rank    int
+	members int
+	id      int
+}
+
+func compareListKeys(sortBy string, a, b listKey) int {
+	switch sortBy {
+	case models.SortByTitle:
+		if a.title != b.title {
+			return strings.Compare(a.title, b.title)
+		}
+	case models.SortByJoined:
+		if !a.joined.Equal(b.joined) {
+			if a.joined.Before(b.joined) {
+				return -1
+			}
+			return 1
+		}
+	case models.SortByRole:
+		if a.rank != b.rank {
+			if a.rank < b.rank {
+				return -1
+			}
+			return 1
+		}
+	case models.SortByMembers:
+		if a.members != b.members {
+			if a.members < b.members {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a.id < b.id:
+		return -1
+	case a.id > b.id:
+		return 1
+	}
+	return 0
+}
+
+func cursorListKey(cursor listCursor) (key listKey, err error) {
+	key.id = cursor.ID
+	switch cursor.SortBy {
+	case models.SortByTitle:
+		key.title = cursor.Key
+	case models.SortByJoined:
+		key.joined, err = time.Parse(time.RFC3339Nano, cursor.Key)
+	case models.SortByRole:
+		key.rank, err = strconv.Atoi(cursor.Key)
+	case models.SortByMembers:
+		key.members, err = strconv.Atoi(cursor.Key)
+	}
+	if err != nil {
+		err = models.ErrorBadCursor
+	}
+	return
+}
+
+// pageKeys сортирует keys, отбрасывает записи до курсора и возвращает индексы записей страницы
+// вместе с курсором следующей страницы.
+func pageKeys(keys []listKey, sortKeys map[string]sortKey, listParams models.ListParams) (indexes []int, nextCursor string, err error) {
+	if _, ok := sortKeys[listParams.SortBy]; !ok {
+		return nil, "", models.ErrorBadSort
+	}
+
+	var after *listKey
+	if listParams.Cursor != "" {
+		cursor, err := decodeCursor(listParams.Cursor, listParams)
+		if err != nil {
+			return nil, "", err
+		}
+		key, err := cursorListKey(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &key
+	}
+
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		c := compareListKeys(listParams.SortBy, keys[order[i]], keys[order[j]])
+		if listParams.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	indexes = make([]int, 0)
+	for _, i := range order {
+		if after != nil {
+			c := compareListKeys(listParams.SortBy, keys[i], *after)
+			if (!listParams.Desc && c <= 0) || (listParams.Desc && c >= 0) {
+				continue
+			}
+		}
+		indexes = append(indexes, i)
+	}
+
+	if len(indexes) > listParams.Limit {
+		indexes = indexes[:listParams.Limit]
+		last := keys[indexes[len(indexes)-1]]
+		cursorKey := last.title
+		switch listParams.SortBy {
+		case models.SortByJoined:
+			cursorKey = last.joined.Format(time.RFC3339Nano)
+		case models.SortByRole:
+			cursorKey = strconv.Itoa(last.rank)
+		case models.SortByCreated:
+			cursorKey = strconv.Itoa(last.id)
+		case models.SortByMembers:
+			cursorKey = strconv.Itoa(last.members)
+		}
+		nextCursor = encodeCursor(listCursor{
+			SortBy: listParams.SortBy,
+			Desc:   listParams.Desc,
+			Key:    cursorKey,
+			ID:     last.id,
+		})
+	}
+	return
+}
+
+func (s *memoryStorage) SelectGroupsByUserID(userID int, groupID int, listParams models.ListParams) (groups []models2.GroupPreview, page models.Page, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	groups = make([]models2.GroupPreview, 0)
+	candidates := make([]models2.GroupPreview, 0)
+	keys := make([]listKey, 0)
+	for key, member := range d.members {
+		group := d.groups[key.groupID]
+		if key.userID != userID || group.StatusID != 1 {
+			continue
+		}
+		if groupID != 0 && key.groupID != groupID {
+			continue
+		}
+		if listParams.RoleID != 0 && member.roleID != listParams.RoleID {
+			continue
+		}
+
+		role := d.roles[member.roleID]
+		joinedAt := member.joinedAt
+		candidates = append(candidates, models2.GroupPreview{
+			ID:          group.ID,
+			Title:       group.Title,
+			Description: group.Description,
+			URL:         group.URL,
+			AvatarURL:   group.AvatarURL,
+			UserID:      userID,
+			UserRole: models2.UserRole{
+				UserID:   userID,
+				GroupID:  groupID,
+				RoleID:   role.ID,
+				RoleName: role.Title,
+				JoinedAt: &joinedAt,
+			},
+			Status:       group.StatusID,
+			Count:        d.countMembers(group.ID),
+			VisibilityID: group.VisibilityID,
+		})
+		keys = append(keys, listKey{title: group.Title, joined: joinedAt, rank: role.Rank, id: group.ID})
+	}
+	page.Total = len(candidates)
+
+	indexes, nextCursor, err := pageKeys(keys, groupSortKeys, listParams)
+	if err != nil {
+		return
+	}
+	for _, i := range indexes {
+		groups = append(groups, candidates[i])
+	}
+	page.NextCursor = nextCursor
+	return
+}
+
+func (s *memoryStorage) SelectPublicGroups(listParams models.ListParams) (groups []models2.GroupPreview, nextCursor string, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	groups = make([]models2.GroupPreview, 0)
+	candidates := make([]models2.GroupPreview, 0)
+	keys := make([]listKey, 0)
+	for _, group := range d.groups {
+		if group.StatusID != 1 || group.VisibilityID != models2.VisibilityPublic {
+			continue
+		}
+		preview := d.preview(group)
+		candidates = append(candidates, preview)
+		keys = append(keys, listKey{members: preview.Count, id: group.ID})
+	}
+
+	indexes, nextCursor, err := pageKeys(keys, map[string]sortKey{models.SortByMembers: publicGroupSortKey}, listParams)
+	if err != nil {
+		return
+	}
+	for _, i := range indexes {
+		groups = append(groups, candidates[i])
+	}
+	return
+}
+
+func (d *memoryData) preview(group models2.Group) models2.GroupPreview {
+	return models2.GroupPreview{
+		ID:           group.ID,
+		Title:        group.Title,
+		Description:  group.Description,
+		URL:          group.URL,
+		AvatarURL:    group.AvatarURL,
+		Status:       group.StatusID,
+		Count:        d.countMembers(group.ID),
+		VisibilityID: group.VisibilityID,
+	}
+}
+
+func paginate(groups []models2.GroupPreview, limit, offset int) []models2.GroupPreview {
+	if offset >= len(groups) {
+		return groups[:0]
+	}
+	groups = groups[offset:]
+	if limit < len(groups) {
+		groups = groups[:limit]
+	}
+	return groups
+}
+
+// Веса полей повторяют setweight в groups.search_vector: A для названия, B для описания.
+const (
+	searchWeightTitle       = 1.0
+	searchWeightDescription = 0.4
+)
+
+// SearchGroups понимает только запросы вида "слово:* & слово:*", которые строит сервис групп.
+func (s *memoryStorage) SearchGroups(userID int, tsQuery, scope string, limit, offset int) (groups []models2.GroupPreview, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	terms := make([]string, 0)
+	for _, term := range strings.Split(tsQuery, "&") {
+		term = strings.TrimSuffix(strings.TrimSpace(term), ":*")
+		if term != "" {
+			terms = append(terms, strings.ToLower(term))
+		}
+	}
+
+	groups = make([]models2.GroupPreview, 0)
+	ranks := make(map[int]float64)
+	for _, group := range d.groups {
+		if group.StatusID != 1 || len(terms) == 0 {
+			continue
+		}
+		member, isMember := d.members[memberKey{group.ID, userID}]
+		isPublic := group.VisibilityID == models2.VisibilityPublic
+		switch scope {
+		case models.SearchScopeMember:
+			if !isMember {
+				continue
+			}
+		case models.SearchScopePublic:
+			if !isPublic {
+				continue
+			}
+		default:
+			if !isMember && !isPublic {
+				continue
+			}
+		}
+
+		rank, ok := searchRank(terms, searchWords(group.Title), searchWords(group.Description))
+		if !ok {
+			continue
+		}
+
+		preview := d.preview(group)
+		preview.UserID = userID
+		if isMember {
+			preview.UserRole = models2.UserRole{
+				UserID:   userID,
+				GroupID:  group.ID,
+				RoleID:   member.roleID,
+				RoleName: d.roles[member.roleID].Title,
+			}
+		}
+		ranks[group.ID] = rank
+		groups = append(groups, preview)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if ranks[groups[i].ID] != ranks[groups[j].ID] {
+			return ranks[groups[i].ID] > ranks[groups[j].ID]
+		}
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return paginate(groups, limit, offset), nil
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchRank требует совпадения каждого префикса хотя бы с одним словом названия или описания.
+func searchRank(terms, titleWords, descriptionWords []string) (rank float64, ok bool) {
+	hasPrefix := func(words []string, term string) bool {
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, term := range terms {
+		switch {
+		case hasPrefix(titleWords, term):
+			rank += searchWeightTitle
+		case hasPrefix(descriptionWords, term):
+			rank += searchWeightDescription
+		default:
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+func (s *memoryStorage) SelectUsersByGroupID(groupID int, listParams models.ListParams) (users []models2.UserRole, page models.Page, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	users = make([]models2.UserRole, 0)
+	candidates := make([]models2.UserRole, 0)
+	keys := make([]listKey, 0)
+	for key, member := range d.members {
+		if key.groupID != groupID {
+			continue
+		}
+		if listParams.RoleID != 0 && member.roleID != listParams.RoleID {
+			continue
+		}
+
+		role := d.roles[member.roleID]
+		joinedAt := member.joinedAt
+		candidates = append(candidates, models2.UserRole{
+			UserID:   key.userID,
+			GroupID:  groupID,
+			RoleID:   role.ID,
+			RoleName: role.Title,
+			JoinedAt: &joinedAt,
+		})
+		keys = append(keys, listKey{joined: joinedAt, rank: role.Rank, id: key.userID})
+	}
+	page.Total = len(candidates)
+
+	indexes, nextCursor, err := pageKeys(keys, memberSortKeys, listParams)
+	if err != nil {
+		return
+	}
+	for _, i := range indexes {
+		users = append(users, candidates[i])
+	}
+	page.NextCursor = nextCursor
+	return
+}
+
+func (s *memoryStorage) InsertUser(groupID, userID, roleID int) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	return d.insertMember(groupID, userID, roleID)
+}
+
+func (d *memoryData) insertMember(groupID, userID, roleID int) (err error) {
+	if _, ok := d.members[memberKey{groupID, userID}]; ok {
+		return fmt.Errorf("exists")
+	}
+	if _, ok := d.groups[groupID]; !ok {
+		return fmt.Errorf("group %d not found", groupID)
+	}
+	if _, ok := d.roles[roleID]; !ok {
+		return fmt.Errorf("role %d not found", roleID)
+	}
+	d.members[memberKey{groupID, userID}] = memoryMember{roleID: roleID, joinedAt: time.Now()}
+	return
+}
+
+func (s *memoryStorage) EditUserRole(groupID, userID, roleID int) (resultRole int, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	member, ok := d.members[memberKey{groupID, userID}]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	member.roleID = roleID
+	d.members[memberKey{groupID, userID}] = member
+	return roleID, nil
+}
+
+func (s *memoryStorage) RemoveUser(groupID, userID int) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	if _, ok := d.members[memberKey{groupID, userID}]; !ok {
+		return fmt.Errorf("removed nothing")
+	}
+	delete(d.members, memberKey{groupID, userID})
+	return
+}
+
+func (s *memoryStorage) SelectLinkByHash(line string) (link models2.GroupInviteLink, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	link, ok := d.links[line]
+	if !ok {
+		return link, sql.ErrNoRows
+	}
+	return link, nil
+}
+
+func (s *memoryStorage) UseLink(line string) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	link, ok := d.links[line]
+	if !ok || (link.MaxUses != 0 && link.Uses >= link.MaxUses) || (link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now())) {
+		return sql.ErrNoRows
+	}
+	link.Uses++
+	d.links[line] = link
+	return
+}
+
+func (s *memoryStorage) RemoveLinkToGroup(groupID int, link string) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.links[link]
+	if !ok || stored.GroupID != groupID {
+		return fmt.Errorf("not removed")
+	}
+	delete(d.links, link)
+	return
+}
+
+func (s *memoryStorage) ListShortLinksToGroup(groupID int) (res []models2.GroupInviteLink, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for _, link := range d.links {
+		if link.GroupID == groupID {
+			res = append(res, link)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Added.Before(res[j].Added)
+	})
+	return
+}
+
+func (s *memoryStorage) AddShortLinkToGroup(groupID int, link models2.GroupInviteLink) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	if _, ok := d.links[link.Link]; ok {
+		return models.ErrorDuplicate
+	}
+	if _, ok := d.groups[groupID]; !ok {
+		return fmt.Errorf("group %d not found", groupID)
+	}
+	d.links[link.Link] = models2.GroupInviteLink{
+		Link:      link.Link,
+		GroupID:   groupID,
+		Added:     time.Now(),
+		Author:    models2.AuthorPack{ID: link.Author.ID},
+		RoleID:    link.RoleID,
+		ExpiresAt: link.ExpiresAt,
+		MaxUses:   link.MaxUses,
+	}
+	return
+}
+
+func (s *memoryStorage) SelectRoles(groupID int) (roles []models2.Role, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	roles = make([]models2.Role, 0)
+	for _, role := range d.roles {
+		if role.GroupID == 0 || role.GroupID == groupID {
+			roles = append(roles, copyRole(role))
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].ID < roles[j].ID
+	})
+	return
+}
+
+func copyRole(role models2.Role) models2.Role {
+	role.Actions = append(make([]int, 0, len(role.Actions)), role.Actions...)
+	return role
+}
+
+func (s *memoryStorage) SelectRole(groupID, roleID int) (role models2.Role, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	role, ok := d.roles[roleID]
+	if !ok || (role.GroupID != 0 && role.GroupID != groupID) {
+		return models2.Role{}, sql.ErrNoRows
+	}
+	return copyRole(role), nil
+}
+
+func (s *memoryStorage) InsertRole(role models2.Role) (roleReturn models2.Role, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	role.ID = d.nextID(rolesTable)
+	role = copyRole(role)
+	d.roles[role.ID] = role
+	return copyRole(role), nil
+}
+
+func (s *memoryStorage) UpdateRole(role models2.Role) (roleReturn models2.Role, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.roles[role.ID]
+	if !ok || stored.GroupID == 0 || stored.GroupID != role.GroupID {
+		return role, sql.ErrNoRows
+	}
+	role = copyRole(role)
+	d.roles[role.ID] = role
+	return copyRole(role), nil
+}
+
+func (s *memoryStorage) DeleteRole(groupID, roleID int) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.roles[roleID]
+	if !ok || stored.GroupID == 0 || stored.GroupID != groupID {
+		return sql.ErrNoRows
+	}
+	if d.countRoleReferences(groupID, roleID) > 0 {
+		return fmt.Errorf("role %d is still referenced", roleID)
+	}
+	delete(d.roles, roleID)
+	return
+}
+
+func (s *memoryStorage) CountUsersWithRole(groupID, roleID int) (count int, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for key, member := range d.members {
+		if key.groupID == groupID && member.roleID == roleID {
+			count++
+		}
+	}
+	return
+}
+
+func (s *memoryStorage) CountRoleReferences(groupID, roleID int) (count int, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	return d.countRoleReferences(groupID, roleID), nil
+}
+
+func (d *memoryData) countRoleReferences(groupID, roleID int) (count int) {
+	for key, member := range d.members {
+		if key.groupID == groupID && member.roleID == roleID {
+			count++
+		}
+	}
+	for _, invitation := range d.invitations {
+		if invitation.GroupID == groupID && invitation.RoleID == roleID {
+			count++
+		}
+	}
+	for _, link := range d.links {
+		if link.GroupID == groupID && link.RoleID == roleID {
+			count++
+		}
+	}
+	if group, ok := d.groups[groupID]; ok && group.JoinRoleID == roleID {
+		count++
+	}
+	return
+}
+
+func (s *memoryStorage) InsertTransfer(transfer models2.OwnershipTransfer) (transferReturn models2.OwnershipTransfer, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for id, stored := range d.transfers {
+		if stored.GroupID == transfer.GroupID && stored.statusID == transferStatusPending {
+			stored.statusID = transferStatusCancelled
+			d.transfers[id] = stored
+		}
+	}
+
+	transfer.ID = d.nextID(groupTransfersTable)
+	transfer.CreateAt = time.Now()
+	d.transfers[transfer.ID] = memoryTransfer{OwnershipTransfer: transfer, statusID: transferStatusPending}
+	return transfer, nil
+}
+
+func (d *memoryData) pendingTransfer(transferID int) (transfer memoryTransfer, ok bool) {
+	transfer, ok = d.transfers[transferID]
+	return transfer, ok && transfer.statusID == transferStatusPending && transfer.ExpiresAt.After(time.Now())
+}
+
+func (s *memoryStorage) SelectTransfer(transferID int) (transfer models2.OwnershipTransfer, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.pendingTransfer(transferID)
+	if !ok {
+		return transfer, sql.ErrNoRows
+	}
+	return stored.OwnershipTransfer, nil
+}
+
+func (s *memoryStorage) SelectTransfersByUserID(userID int) (transfers []models2.OwnershipTransfer, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	transfers = make([]models2.OwnershipTransfer, 0)
+	for id, stored := range d.transfers {
+		if stored.FromUserID != userID && stored.ToUserID != userID {
+			continue
+		}
+		if _, ok := d.pendingTransfer(id); ok {
+			transfers = append(transfers, stored.OwnershipTransfer)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if !transfers[i].CreateAt.Equal(transfers[j].CreateAt) {
+			return transfers[i].CreateAt.After(transfers[j].CreateAt)
+		}
+		return transfers[i].ID > transfers[j].ID
+	})
+	return
+}
+
+func (s *memoryStorage) CancelTransfer(transferID int) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.transfers[transferID]
+	if !ok || stored.statusID != transferStatusPending {
+		return sql.ErrNoRows
+	}
+	stored.statusID = transferStatusCancelled
+	d.transfers[transferID] = stored
+	return
+}
+
+func (s *memoryStorage) AcceptTransfer(transfer models2.OwnershipTransfer, creatorRoleID, adminRoleID int) (err error) {
+	return s.atomic(func(d *memoryData) error {
+		stored, ok := d.pendingTransfer(transfer.ID)
+		if !ok {
+			return sql.ErrNoRows
+		}
+		stored.statusID = transferStatusAccepted
+		d.transfers[transfer.ID] = stored
+
+		group, ok := d.groups[transfer.GroupID]
+		if !ok || group.StatusID != models2.GroupActive {
+			return models.ErrorStaleTransfer
+		}
+		group.CreateBy = transfer.ToUserID
+		d.groups[transfer.GroupID] = group
+
+		proposer, ok := d.members[memberKey{transfer.GroupID, transfer.FromUserID}]
+		if !ok || proposer.roleID != creatorRoleID {
+			return models.ErrorStaleTransfer
+		}
+		proposer.roleID = adminRoleID
+		d.members[memberKey{transfer.GroupID, transfer.FromUserID}] = proposer
+
+		member, ok := d.members[memberKey{transfer.GroupID, transfer.ToUserID}]
+		if !ok {
+			return fmt.Errorf("new owner is not a member")
+		}
+		member.roleID = creatorRoleID
+		d.members[memberKey{transfer.GroupID, transfer.ToUserID}] = member
+		return nil
+	})
+}
+
+func (s *memoryStorage) InsertJoinRequest(groupID, userID int) (request models2.JoinRequest, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for _, stored := range d.joinRequests {
+		if stored.GroupID == groupID && stored.UserID == userID && stored.StatusID == models2.JoinRequestPending {
+			return request, models.ErrorDuplicate
+		}
+	}
+
+	request = models2.JoinRequest{
+		ID:       d.nextID(joinRequestsTable),
+		GroupID:  groupID,
+		UserID:   userID,
+		StatusID: models2.JoinRequestPending,
+		CreateAt: time.Now(),
+	}
+	d.joinRequests[request.ID] = request
+	return request, nil
+}
+
+func (s *memoryStorage) SelectJoinRequests(groupID int) (requests []models2.JoinRequest, err error) {
+	return s.selectJoinRequests(func(request models2.JoinRequest) bool {
+		return request.GroupID == groupID && request.StatusID == models2.JoinRequestPending
+	}, false)
+}
+
+func (s *memoryStorage) SelectJoinRequestsByUserID(userID int) (requests []models2.JoinRequest, err error) {
+	return s.selectJoinRequests(func(request models2.JoinRequest) bool {
+		return request.UserID == userID
+	}, true)
+}
+
+func (s *memoryStorage) selectJoinRequests(match func(request models2.JoinRequest) bool, newestFirst bool) (requests []models2.JoinRequest, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	requests = make([]models2.JoinRequest, 0)
+	for _, request := range d.joinRequests {
+		if match(request) {
+			requests = append(requests, request)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if newestFirst {
+			return requests[i].ID > requests[j].ID
+		}
+		return requests[i].ID < requests[j].ID
+	})
+	return
+}
+
+func (s *memoryStorage) ApproveJoinRequest(groupID, requestID, roleID, actorID int) (request models2.JoinRequest, err error) {
+	err = s.atomic(func(d *memoryData) (err error) {
+		request, err = d.decideJoinRequest(groupID, requestID, actorID, models2.JoinRequestApproved)
+		if err != nil {
+			return
+		}
+		if _, ok := d.members[memberKey{groupID, request.UserID}]; ok {
+			return
+		}
+		return d.insertMember(groupID, request.UserID, roleID)
+	})
+	return
+}
+
+func (s *memoryStorage) RejectJoinRequest(groupID, requestID, actorID int) (request models2.JoinRequest, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	return d.decideJoinRequest(groupID, requestID, actorID, models2.JoinRequestRejected)
+}
+
+func (d *memoryData) decideJoinRequest(groupID, requestID, actorID, statusID int) (request models2.JoinRequest, err error) {
+	request, ok := d.joinRequests[requestID]
+	if !ok || request.GroupID != groupID || request.StatusID != models2.JoinRequestPending {
+		return models2.JoinRequest{}, sql.ErrNoRows
+	}
+	decidedAt := time.Now()
+	request.StatusID = statusID
+	request.DecidedBy = actorID
+	request.DecidedAt = &decidedAt
+	d.joinRequests[requestID] = request
+	return request, nil
+}
+
+func (s *memoryStorage) InsertInvitation(invitation models2.Invitation) (invitationReturn models2.Invitation, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for _, stored := range d.invitations {
+		if stored.GroupID == invitation.GroupID && stored.UserID == invitation.UserID && stored.StatusID == models2.InvitationPending {
+			return invitation, models.ErrorDuplicate
+		}
+	}
+
+	invitation.ID = d.nextID(invitationsTable)
+	invitation.StatusID = models2.InvitationPending
+	invitation.CreateAt = time.Now()
+	d.invitations[invitation.ID] = memoryInvitation{Invitation: models2.Invitation{
+		ID:        invitation.ID,
+		GroupID:   invitation.GroupID,
+		UserID:    invitation.UserID,
+		RoleID:    invitation.RoleID,
+		InvitedBy: invitation.InvitedBy,
+		StatusID:  invitation.StatusID,
+		CreateAt:  invitation.CreateAt,
+	}}
+	return invitation, nil
+}
+
+func (s *memoryStorage) SelectInvitationsByUserID(userID int) (invitations []models2.Invitation, err error) {
+	return s.selectInvitations(func(d *memoryData, invitation models2.Invitation) bool {
+		return invitation.UserID == userID && invitation.StatusID == models2.InvitationPending &&
+			d.groups[invitation.GroupID].StatusID == 1
+	}, true)
+}
+
+func (s *memoryStorage) SelectInvitationsByGroupID(groupID int) (invitations []models2.Invitation, err error) {
+	return s.selectInvitations(func(d *memoryData, invitation models2.Invitation) bool {
+		return invitation.GroupID == groupID && invitation.StatusID == models2.InvitationPending
+	}, false)
+}
+
+func (s *memoryStorage) selectInvitations(match func(d *memoryData, invitation models2.Invitation) bool, newestFirst bool) (invitations []models2.Invitation, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	invitations = make([]models2.Invitation, 0)
+	for _, stored := range d.invitations {
+		if !match(d, stored.Invitation) {
+			continue
+		}
+		invitation := stored.Invitation
+		invitation.GroupTitle = d.groups[invitation.GroupID].Title
+		invitation.RoleName = d.roles[invitation.RoleID].Title
+		if stored.deliveryError != "" {
+			invitation.Delivery = &models2.InvitationDelivery{StatusID: models2.DeliveryFailed, LastError: stored.deliveryError}
+		}
+		invitations = append(invitations, invitation)
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		if newestFirst {
+			return invitations[i].ID > invitations[j].ID
+		}
+		return invitations[i].ID < invitations[j].ID
+	})
+	return
+}
+
+func (s *memoryStorage) AcceptInvitation(invitationID, userID int) (invitation models2.Invitation, err error) {
+	err = s.atomic(func(d *memoryData) (err error) {
+		invitation, err = d.decideInvitation(invitationID, func(stored models2.Invitation) bool {
+			return stored.UserID == userID
+		}, models2.InvitationAccepted)
+		if err != nil {
+			return
+		}
+		if _, ok := d.members[memberKey{invitation.GroupID, invitation.UserID}]; ok {
+			return
+		}
+		return d.insertMember(invitation.GroupID, invitation.UserID, invitation.RoleID)
+	})
+	return
+}
+
+func (s *memoryStorage) DeclineInvitation(invitationID, userID int) (invitation models2.Invitation, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	return d.decideInvitation(invitationID, func(stored models2.Invitation) bool {
+		return stored.UserID == userID
+	}, models2.InvitationDeclined)
+}
+
+func (s *memoryStorage) RevokeInvitation(groupID, invitationID int) (invitation models2.Invitation, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	return d.decideInvitation(invitationID, func(stored models2.Invitation) bool {
+		return stored.GroupID == groupID
+	}, models2.InvitationRevoked)
+}
+
+func (d *memoryData) decideInvitation(invitationID int, match func(stored models2.Invitation) bool, statusID int) (invitation models2.Invitation, err error) {
+	stored, ok := d.invitations[invitationID]
+	if !ok || !match(stored.Invitation) || stored.StatusID != models2.InvitationPending {
+		return invitation, sql.ErrNoRows
+	}
+	decidedAt := time.Now()
+	stored.StatusID = statusID
+	stored.DecidedAt = &decidedAt
+	d.invitations[invitationID] = stored
+	return stored.Invitation, nil
+}
+
+func (s *memoryStorage) SelectInvitation(invitationID int) (invitation models2.Invitation, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.invitations[invitationID]
+	if !ok {
+		return invitation, sql.ErrNoRows
+	}
+	return stored.Invitation, nil
+}
+
+func (s *memoryStorage) SelectInvitationByLink(link string) (invitation models2.Invitation, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	for _, stored := range d.invitations {
+		if stored.link == link {
+			return stored.Invitation, nil
+		}
+	}
+	return invitation, sql.ErrNoRows
+}
+
+func (s *memoryStorage) SetInvitationMessage(invitationID, messageID int, link string) (previousLink string, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	stored, ok := d.invitations[invitationID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	// Как ON DELETE SET NULL: удалённая ссылка не возвращается.
+	if _, ok = d.links[stored.link]; ok {
+		previousLink = stored.link
+	}
+	stored.messageID = messageID
+	stored.link = link
+	stored.deliveryError = ""
+	d.invitations[invitationID] = stored
+	return
+}
+
+func (s *memoryStorage) SetInvitationDeliveryError(invitationID int, reason string) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	if stored, ok := d.invitations[invitationID]; ok {
+		stored.deliveryError = reason
+		d.invitations[invitationID] = stored
+	}
+	return
+}
+
+func (s *memoryStorage) InsertAuditEntry(entry models2.AuditEntry) (err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	entry.ID = d.nextID(auditTable)
+	entry.CreateAt = time.Now()
+	d.audit[entry.ID] = entry
+	return
+}
+
+func (s *memoryStorage) SelectAuditEntries(request models.AuditListRequest) (entries []models2.AuditEntry, page models.Page, err error) {
+	d := s.lock()
+	defer s.unlock()
+
+	entries = make([]models2.AuditEntry, 0)
+	candidates := make([]models2.AuditEntry, 0)
+	keys := make([]listKey, 0)
+	for _, entry := range d.audit {
+		if entry.GroupID != request.Group {
+			continue
+		}
+		if request.ActorID != 0 && entry.ActorID != request.ActorID {
+			continue
+		}
+		if request.Action != "" && entry.Action != request.Action {
+			continue
+		}
+		candidates = append(candidates, entry)
+		keys = append(keys, listKey{id: entry.ID})
+	}
+	page.Total = len(candidates)
+
+	indexes, nextCursor, err := pageKeys(keys, map[string]sortKey{models.SortByCreated: auditSortKey}, request.ListParams)
+	if err != nil {
+		return
+	}
+	for _, i := range indexes {
+		entries = append(entries, candidates[i])
+	}
+	page.NextCursor = nextCursor
+	return
+}
@@ -6,37 +6,102 @@ import (
 	"github.com/Solar-2020/Group-Backend/internal/models"
 	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
 	"github.com/lib/pq"
+	"strconv"
+	"time"
 )
 
 const (
 	queryReturningID        = "RETURNING id;"
 	userGroupsTable         = "users_groups"
 	groupLinksTable         = "group_links"
+	rolesTable              = "roles"
+	permissionTable         = "permission"
+	groupTransfersTable     = "group_transfers"
+	joinRequestsTable       = "join_requests"
+	invitationsTable        = "invitations"
+	auditTable              = "audit_log"
 	pgErrorUniqueConstraint = "23505"
 )
 
+const (
+	transferStatusPending   = 1
+	transferStatusAccepted  = 2
+	transferStatusCancelled = 3
+)
+
 type Storage interface {
 	InsertGroup(group models2.Group) (groupReturn models2.Group, err error)
 	UpdateGroup(group models2.Group) (groupReturn models2.Group, err error)
 	UpdateGroupStatus(groupID, statusID int) (group models2.Group, err error)
+	SelectDeletedGroups(userID, roleID int, deletedAfter time.Time) (groups []models2.DeletedGroup, err error)
+	PurgeDeletedGroups(deletedBefore time.Time, limit int) (groupIDs []int, err error)
 	SelectGroupByID(groupID int) (group models2.Group, err error)
+	SelectGroupIDBySlug(slug string) (groupID int, err error)
 	SelectGroupRole(groupID, userID int) (role models2.UserRole, err error)
 	SelectPermission(actionID, roleID int) (permission models.Permission, err error)
-	SelectGroupsByUserID(userID int, groupID int) (group []models2.GroupPreview, err error)
+	SelectGroupsByUserID(userID int, groupID int, listParams models.ListParams) (groups []models2.GroupPreview, page models.Page, err error)
+	SelectPublicGroups(listParams models.ListParams) (groups []models2.GroupPreview, nextCursor string, err error)
+	SearchGroups(userID int, tsQuery, scope string, limit, offset int) (groups []models2.GroupPreview, err error)
 
-	SelectUsersByGroupID(groupID int) (users []models2.UserRole, err error)
+	SelectUsersByGroupID(groupID int, listParams models.ListParams) (users []models2.UserRole, page models.Page, err error)
 	InsertUser(groupID, userID, roleID int) (err error)
 	EditUserRole(groupID, userID, roleID int) (resultRole int, err error)
 	RemoveUser(groupID, userID int) (err error)
 
-	HashToGroupID(line string) (groupID int, err error)
+	SelectLinkByHash(line string) (link models2.GroupInviteLink, err error)
+	UseLink(line string) (err error)
 	RemoveLinkToGroup(groupID int, link string) (err error)
 	ListShortLinksToGroup(groupID int) (res []models2.GroupInviteLink, err error)
-	AddShortLinkToGroup(groupID int, link string, author int) (err error)
+	AddShortLinkToGroup(groupID int, link models2.GroupInviteLink) (err error)
+
+	SelectRoles(groupID int) (roles []models2.Role, err error)
+	SelectRole(groupID, roleID int) (role models2.Role, err error)
+	InsertRole(role models2.Role) (roleReturn models2.Role, err error)
+	UpdateRole(role models2.Role) (roleReturn models2.Role, err error)
+	DeleteRole(groupID, roleID int) (err error)
+	CountUsersWithRole(groupID, roleID int) (count int, err error)
+	CountRoleReferences(groupID, roleID int) (count int, err error)
+
+	InsertTransfer(transfer models2.OwnershipTransfer) (transferReturn models2.OwnershipTransfer, err error)
+	SelectTransfer(transferID int) (transfer models2.OwnershipTransfer, err error)
+	SelectTransfersByUserID(userID int) (transfers []models2.OwnershipTransfer, err error)
+	CancelTransfer(transferID int) (err error)
+	AcceptTransfer(transfer models2.OwnershipTransfer, creatorRoleID, adminRoleID int) (err error)
+
+	InsertJoinRequest(groupID, userID int) (request models2.JoinRequest, err error)
+	SelectJoinRequests(groupID int) (requests []models2.JoinRequest, err error)
+	SelectJoinRequestsByUserID(userID int) (requests []models2.JoinRequest, err error)
+	ApproveJoinRequest(groupID, requestID, roleID, actorID int) (request models2.JoinRequest, err error)
+	RejectJoinRequest(groupID, requestID, actorID int) (request models2.JoinRequest, err error)
+
+	InsertInvitation(invitation models2.Invitation) (invitationReturn models2.Invitation, err error)
+	SelectInvitationsByUserID(userID int) (invitations []models2.Invitation, err error)
+	SelectInvitationsByGroupID(groupID int) (invitations []models2.Invitation, err error)
+	AcceptInvitation(invitationID, userID int) (invitation models2.Invitation, err error)
+	DeclineInvitation(invitationID, userID int) (invitation models2.Invitation, err error)
+	RevokeInvitation(groupID, invitationID int) (invitation models2.Invitation, err error)
+	SelectInvitation(invitationID int) (invitation models2.Invitation, err error)
+	SelectInvitationByLink(link string) (invitation models2.Invitation, err error)
+	SetInvitationMessage(invitationID, messageID int, link string) (previousLink string, err error)
+	SetInvitationDeliveryError(invitationID int, reason string) (err error)
+
+	InsertAuditEntry(entry models2.AuditEntry) (err error)
+	SelectAuditEntries(request models.AuditListRequest) (entries []models2.AuditEntry, page models.Page, err error)
+
+	WithTx(fn func(tx Storage) error) (err error)
+}
+
+// queryer — общая часть *sql.DB и *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 type storage struct {
 	db *sql.DB
+	// tx задан у хранилища, полученного внутри WithTx.
+	tx *sql.Tx
 }
 
 func NewStorage(db *sql.DB) Storage {
@@ -45,13 +110,71 @@ func NewStorage(db *sql.DB) Storage {
 	}
 }
 
+// WithTx выполняет fn в одной транзакции: если fn вернула ошибку или запаниковала, все изменения откатываются.
+// Вложенный вызов WithTx выполняется в уже открытой транзакции.
+func (s *storage) WithTx(fn func(tx Storage) error) (err error) {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	err = fn(&storage{db: s.db, tx: tx})
+	if err != nil {
+		_ = tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+func (s *storage) conn() queryer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// begin открывает транзакцию для многошаговых методов. Внутри WithTx используется внешняя транзакция,
+// и фиксирует её только сам WithTx.
+func (s *storage) begin() (tx *sql.Tx, finish func(err error) error, err error) {
+	if s.tx != nil {
+		return s.tx, func(err error) error { return err }, nil
+	}
+
+	tx, err = s.db.Begin()
+	if err != nil {
+		return
+	}
+	finish = func(err error) error {
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+	return
+}
+
 func (s *storage) InsertGroup(group models2.Group) (groupReturn models2.Group, err error) {
 	const sqlQuery = `
-	INSERT INTO groups(title, description, url, create_by, avatar_url)
-	VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO groups(title, description, url, create_by, avatar_url, join_approval, join_role_id, visibility_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	RETURNING id, create_at, status_id;`
 
-	err = s.db.QueryRow(sqlQuery, group.Title, group.Description, group.URL, group.CreateBy, group.AvatarURL).Scan(&group.ID, &group.CreatAt, &group.StatusID)
+	err = s.conn().QueryRow(sqlQuery, group.Title, group.Description, group.URL, group.CreateBy, group.AvatarURL,
+		group.JoinApproval, group.JoinRoleID, group.VisibilityID).Scan(&group.ID, &group.CreatAt, &group.StatusID)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == pgErrorUniqueConstraint {
+		err = models.ErrorDuplicate
+	}
 	return group, err
 }
 
@@ -61,22 +184,123 @@ func (s *storage) UpdateGroup(group models2.Group) (groupReturn models2.Group, e
 	SET title=$1,
 		description=$2,
 		url=$3,
-		avatar_url=$4
-	WHERE id = $5
-	RETURNING id, title, description, url, create_by, create_at, status_id, avatar_url`
+		avatar_url=$4,
+		join_approval=$5,
+		join_role_id=$6,
+		visibility_id=$7
+	WHERE id = $8
+	RETURNING id, title, description, url, create_by, create_at, status_id, avatar_url, join_approval, join_role_id, visibility_id`
 
-	err = s.db.QueryRow(sqlQuery, group.Title, group.Description, group.URL, group.AvatarURL, group.ID).Scan(&group.ID, &group.Title, &group.Description, &group.URL, &group.CreateBy, &group.CreatAt, &group.StatusID, &group.AvatarURL)
+	err = s.conn().QueryRow(sqlQuery, group.Title, group.Description, group.URL, group.AvatarURL, group.JoinApproval, group.JoinRoleID,
+		group.VisibilityID, group.ID).
+		Scan(&group.ID, &group.Title, &group.Description, &group.URL, &group.CreateBy, &group.CreatAt, &group.StatusID, &group.AvatarURL,
+			&group.JoinApproval, &group.JoinRoleID, &group.VisibilityID)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == pgErrorUniqueConstraint {
+		err = models.ErrorDuplicate
+	}
 	return group, err
 }
 
+// UpdateGroupStatus запоминает момент удаления группы, от которого отсчитывается срок её хранения.
 func (s *storage) UpdateGroupStatus(groupID, statusID int) (group models2.Group, err error) {
 	const sqlQuery = `
 	UPDATE groups
-	SET status_id = $1
+	SET status_id = $1,
+		deleted_at = CASE WHEN $1 = $3 THEN now() END
 	WHERE id = $2
-	RETURNING id, title, description, url, create_by, create_at, status_id, avatar_url;`
+	RETURNING id, title, description, url, create_by, create_at, status_id, avatar_url, join_approval, join_role_id,
+		visibility_id, deleted_at;`
+
+	var deletedAt sql.NullTime
+	err = s.conn().QueryRow(sqlQuery, statusID, groupID, models2.GroupDeleted).Scan(&group.ID, &group.Title, &group.Description,
+		&group.URL, &group.CreateBy, &group.CreatAt, &group.StatusID, &group.AvatarURL, &group.JoinApproval, &group.JoinRoleID,
+		&group.VisibilityID, &deletedAt)
+	if deletedAt.Valid {
+		group.DeletedAt = &deletedAt.Time
+	}
+	return
+}
+
+// SelectDeletedGroups возвращает удалённые после deletedAfter группы, в которых у пользователя роль roleID.
+func (s *storage) SelectDeletedGroups(userID, roleID int, deletedAfter time.Time) (groups []models2.DeletedGroup, err error) {
+	groups = make([]models2.DeletedGroup, 0)
+	const sqlQuery = `
+	SELECT g.id, g.title, g.url, g.avatar_url, g.members, g.deleted_at
+	FROM groups AS g
+			 JOIN %s AS ug ON ug.group_id = g.id
+	WHERE ug.user_id = $1 AND ug.role_id = $2 AND g.status_id = $3 AND g.deleted_at > $4
+	ORDER BY g.deleted_at DESC, g.id;`
+
+	rows, err := s.conn().Query(fmt.Sprintf(sqlQuery, userGroupsTable), userID, roleID, models2.GroupDeleted, deletedAfter)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group models2.DeletedGroup
+		err = rows.Scan(&group.ID, &group.Title, &group.URL, &group.AvatarURL, &group.Count, &group.DeletedAt)
+		if err != nil {
+			return
+		}
+		groups = append(groups, group)
+	}
+	err = rows.Err()
+	return
+}
+
+// PurgeDeletedGroups окончательно удаляет не больше limit групп, удалённых до deletedBefore, вместе с участниками
+// и ссылками. Остальные данные группы удаляются каскадно. Группы, которые обрабатывает другой экземпляр сервиса, пропускаются.
+func (s *storage) PurgeDeletedGroups(deletedBefore time.Time, limit int) (groupIDs []int, err error) {
+	groupIDs = make([]int, 0)
+	const sqlQuerySelect = `
+	SELECT id
+	FROM groups
+	WHERE status_id = $1 AND deleted_at <= $2
+	ORDER BY deleted_at
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED;`
+
+	const sqlQueryDelete = `
+	DELETE FROM %s WHERE group_id = ANY($1);`
+
+	const sqlQueryDeleteGroups = `
+	DELETE FROM groups WHERE id = ANY($1);`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	rows, err := tx.Query(sqlQuerySelect, models2.GroupDeleted, deletedBefore, limit)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var groupID int
+		err = rows.Scan(&groupID)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(groupIDs) == 0 {
+		return
+	}
+
+	for _, table := range []string{userGroupsTable, groupLinksTable} {
+		_, err = tx.Exec(fmt.Sprintf(sqlQueryDelete, table), pq.Array(groupIDs))
+		if err != nil {
+			return
+		}
+	}
 
-	err = s.db.QueryRow(sqlQuery, statusID, groupID).Scan(&group.ID, &group.Title, &group.Description, &group.URL, &group.CreateBy, &group.CreatAt, &group.StatusID, &group.AvatarURL)
+	_, err = tx.Exec(sqlQueryDeleteGroups, pq.Array(groupIDs))
 	return
 }
 
@@ -90,12 +314,27 @@ func (s *storage) SelectGroupByID(groupID int) (group models2.Group, err error)
 		   g.create_at,
 		   g.status_id,
 		   g.avatar_url,
-		   g.members
+		   g.members,
+		   g.join_approval,
+		   g.join_role_id,
+		   g.visibility_id
 	FROM groups as g
 	WHERE g.id = $1 AND g.status_id = 1;`
 
-	err = s.db.QueryRow(sqlQuery, groupID).Scan(&group.ID, &group.Title, &group.Description, &group.URL,
-		&group.CreateBy, &group.CreatAt, &group.StatusID, &group.AvatarURL, &group.Count)
+	err = s.conn().QueryRow(sqlQuery, groupID).Scan(&group.ID, &group.Title, &group.Description, &group.URL,
+		&group.CreateBy, &group.CreatAt, &group.StatusID, &group.AvatarURL, &group.Count, &group.JoinApproval, &group.JoinRoleID,
+		&group.VisibilityID)
+	return
+}
+
+// SelectGroupIDBySlug ищет среди всех групп, включая удалённые: ссылка удалённой группы остаётся занятой.
+func (s *storage) SelectGroupIDBySlug(slug string) (groupID int, err error) {
+	const sqlQuery = `
+	SELECT g.id
+	FROM groups as g
+	WHERE g.url = $1;`
+
+	err = s.conn().QueryRow(sqlQuery, slug).Scan(&groupID)
 	return
 }
 
@@ -108,7 +347,7 @@ func (s *storage) SelectGroupRole(groupID, userID int) (role models2.UserRole, e
 			 JOIN roles AS r ON ug.role_id = r.id
 	WHERE ug.group_id = $1 AND ug.user_id = $2;`
 
-	err = s.db.QueryRow(sqlQuery, groupID, userID).Scan(&role.RoleID, &role.RoleName)
+	err = s.conn().QueryRow(sqlQuery, groupID, userID).Scan(&role.RoleID, &role.RoleName)
 	return
 }
 
@@ -118,33 +357,80 @@ func (s *storage) SelectPermission(actionID, roleID int) (permission models.Perm
 	FROM permission as rp
 	WHERE rp.action_id = $1 AND rp.role_id = $2;`
 
-	err = s.db.QueryRow(sqlQuery, actionID, roleID).Scan(&permission.ActionID, &permission.RoleID)
+	err = s.conn().QueryRow(sqlQuery, actionID, roleID).Scan(&permission.ActionID, &permission.RoleID)
 
 	return
 }
 
-func (s *storage) SelectUsersByGroupID(groupID int) (users []models2.UserRole, err error) {
+var memberSortKeys = map[string]sortKey{
+	models.SortByJoined: {expr: "ug.joined_at", cast: "timestamptz"},
+	models.SortByRole:   {expr: "r.rank", cast: "int"},
+}
+
+func (s *storage) SelectUsersByGroupID(groupID int, listParams models.ListParams) (users []models2.UserRole, page models.Page, err error) {
 	users = make([]models2.UserRole, 0)
 	const sqlQuery = `
-	SELECT ug.user_id, ug.role_id, r.title
+	SELECT ug.user_id, ug.role_id, r.title, ug.joined_at, r.rank
 	FROM users_groups as ug
 			 JOIN roles AS r ON ug.role_id = r.id
-	WHERE ug.group_id = $1;`
+	WHERE ug.group_id = $1`
+	params := []interface{}{
+		groupID,
+	}
+	query := sqlQuery
+	if listParams.RoleID != 0 {
+		query += fmt.Sprintf(` AND ug.role_id = $%d`, len(params)+1)
+		params = append(params, listParams.RoleID)
+	}
+
+	err = s.conn().QueryRow(countQuery(query), params...).Scan(&page.Total)
+	if err != nil {
+		return
+	}
+
+	key, ok := memberSortKeys[listParams.SortBy]
+	if !ok {
+		return users, page, models.ErrorBadSort
+	}
+	query, params, err = appendKeyset(query, params, key, "ug.user_id", listParams)
+	if err != nil {
+		return
+	}
 
-	rows, err := s.db.Query(sqlQuery, groupID)
+	rows, err := s.conn().Query(query, params...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
+	ranks := make([]int, 0)
 	for rows.Next() {
 		var tempUser models2.UserRole
+		var joinedAt time.Time
+		var rank int
 		tempUser.GroupID = groupID
-		err = rows.Scan(&tempUser.UserID, &tempUser.RoleID, &tempUser.RoleName)
+		err = rows.Scan(&tempUser.UserID, &tempUser.RoleID, &tempUser.RoleName, &joinedAt, &rank)
 		if err != nil {
 			return
 		}
+		tempUser.JoinedAt = &joinedAt
 		users = append(users, tempUser)
+		ranks = append(ranks, rank)
+	}
+
+	if len(users) > listParams.Limit {
+		users = users[:listParams.Limit]
+		last := users[len(users)-1]
+		cursorKey := last.JoinedAt.Format(time.RFC3339Nano)
+		if listParams.SortBy == models.SortByRole {
+			cursorKey = strconv.Itoa(ranks[len(users)-1])
+		}
+		page.NextCursor = encodeCursor(listCursor{
+			SortBy: listParams.SortBy,
+			Desc:   listParams.Desc,
+			Key:    cursorKey,
+			ID:     last.UserID,
+		})
 	}
 
 	return
@@ -155,7 +441,7 @@ func (s *storage) InsertUser(groupID, userID, roleID int) (err error) {
 	INSERT INTO users_groups(group_id, user_id, role_id)
 	VALUES ($1, $2, $3);`
 
-	_, err = s.db.Exec(sqlQuery, groupID, userID, roleID)
+	_, err = s.conn().Exec(sqlQuery, groupID, userID, roleID)
 	if pgErr, ok := err.(*pq.Error); ok {
 		if pgErr.Code == pgErrorUniqueConstraint {
 			err = fmt.Errorf("exists")
@@ -170,7 +456,7 @@ func (s *storage) EditUserRole(groupID, userID, roleID int) (resultRole int, err
 	UPDATE %s SET role_id=$1 WHERE group_id=$2 AND user_id=$3
 	RETURNING role_id`
 
-	row := s.db.QueryRow(
+	row := s.conn().QueryRow(
 		fmt.Sprintf(sqlQuery, userGroupsTable),
 		roleID, groupID, userID)
 	if row == nil {
@@ -184,7 +470,7 @@ func (s *storage) EditUserRole(groupID, userID, roleID int) (resultRole int, err
 func (s *storage) RemoveUser(groupID, userID int) (err error) {
 	const sqlQuery = `
 	DELETE FROM %s WHERE group_id=$1 AND user_id=$2`
-	res, err := s.db.Exec(fmt.Sprintf(sqlQuery, userGroupsTable), groupID, userID)
+	res, err := s.conn().Exec(fmt.Sprintf(sqlQuery, userGroupsTable), groupID, userID)
 	if err != nil {
 		return
 	}
@@ -195,7 +481,13 @@ func (s *storage) RemoveUser(groupID, userID int) (err error) {
 	return
 }
 
-func (s *storage) SelectGroupsByUserID(userID int, groupID int) (groups []models2.GroupPreview, err error) {
+var groupSortKeys = map[string]sortKey{
+	models.SortByTitle:  {expr: "g.title", cast: "text"},
+	models.SortByJoined: {expr: "ug.joined_at", cast: "timestamptz"},
+	models.SortByRole:   {expr: "r.rank", cast: "int"},
+}
+
+func (s *storage) SelectGroupsByUserID(userID int, groupID int, listParams models.ListParams) (groups []models2.GroupPreview, page models.Page, err error) {
 	const sqlQuery = `
 	SELECT g.id,
 		   g.title,
@@ -205,7 +497,10 @@ func (s *storage) SelectGroupsByUserID(userID int, groupID int) (groups []models
 		   r.id,
 		   r.title,
 		   g.status_id,
-		   g.members
+		   g.members,
+		   g.visibility_id,
+		   ug.joined_at,
+		   r.rank
 	FROM groups AS g
 			 JOIN users_groups AS ug ON g.id = ug.group_id
 			 JOIN roles AS r ON ug.role_id = r.id
@@ -218,46 +513,236 @@ func (s *storage) SelectGroupsByUserID(userID int, groupID int) (groups []models
 		query += ` AND ug.group_id=$2`
 		params = append(params, groupID)
 	}
+	if listParams.RoleID != 0 {
+		query += fmt.Sprintf(` AND ug.role_id = $%d`, len(params)+1)
+		params = append(params, listParams.RoleID)
+	}
 
 	groups = make([]models2.GroupPreview, 0)
-	rows, err := s.db.Query(query, params...)
+	err = s.conn().QueryRow(countQuery(query), params...).Scan(&page.Total)
+	if err != nil {
+		return
+	}
+
+	key, ok := groupSortKeys[listParams.SortBy]
+	if !ok {
+		return groups, page, models.ErrorBadSort
+	}
+	query, params, err = appendKeyset(query, params, key, "g.id", listParams)
+	if err != nil {
+		return
+	}
+
+	rows, err := s.conn().Query(query, params...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
+	ranks := make([]int, 0)
 	for rows.Next() {
 		var tempGroup models2.GroupPreview
+		var joinedAt time.Time
+		var rank int
 		err = rows.Scan(&tempGroup.ID, &tempGroup.Title, &tempGroup.Description, &tempGroup.URL,
-			&tempGroup.AvatarURL, &tempGroup.UserRole.RoleID, &tempGroup.UserRole.RoleName, &tempGroup.Status, &tempGroup.Count)
+			&tempGroup.AvatarURL, &tempGroup.UserRole.RoleID, &tempGroup.UserRole.RoleName, &tempGroup.Status, &tempGroup.Count,
+			&tempGroup.VisibilityID, &joinedAt, &rank)
 		if err != nil {
 			return
 		}
 		tempGroup.UserID = userID
 		tempGroup.UserRole.UserID = userID
 		tempGroup.UserRole.GroupID = groupID
+		tempGroup.UserRole.JoinedAt = &joinedAt
+		groups = append(groups, tempGroup)
+		ranks = append(ranks, rank)
+	}
+
+	if len(groups) > listParams.Limit {
+		groups = groups[:listParams.Limit]
+		last := groups[len(groups)-1]
+		var cursorKey string
+		switch listParams.SortBy {
+		case models.SortByTitle:
+			cursorKey = last.Title
+		case models.SortByJoined:
+			cursorKey = last.UserRole.JoinedAt.Format(time.RFC3339Nano)
+		case models.SortByRole:
+			cursorKey = strconv.Itoa(ranks[len(groups)-1])
+		}
+		page.NextCursor = encodeCursor(listCursor{
+			SortBy: listParams.SortBy,
+			Desc:   listParams.Desc,
+			Key:    cursorKey,
+			ID:     last.ID,
+		})
+	}
+	return
+}
+
+var publicGroupSortKey = sortKey{expr: "g.members", cast: "int"}
+
+// SelectPublicGroups отдаёт публичные группы от самых многолюдных; listParams.SortBy должен быть
+// models.SortByMembers.
+func (s *storage) SelectPublicGroups(listParams models.ListParams) (groups []models2.GroupPreview, nextCursor string, err error) {
+	const sqlQuery = `
+	SELECT g.id,
+		   g.title,
+		   g.description,
+		   g.url,
+		   g.avatar_url,
+		   g.status_id,
+		   g.members,
+		   g.visibility_id
+	FROM groups AS g
+	WHERE g.status_id = 1 AND g.visibility_id = $1`
+	params := []interface{}{
+		models2.VisibilityPublic,
+	}
+
+	groups = make([]models2.GroupPreview, 0)
+	query, params, err := appendKeyset(sqlQuery, params, publicGroupSortKey, "g.id", listParams)
+	if err != nil {
+		return
+	}
+
+	rows, err := s.conn().Query(query, params...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tempGroup models2.GroupPreview
+		err = rows.Scan(&tempGroup.ID, &tempGroup.Title, &tempGroup.Description, &tempGroup.URL,
+			&tempGroup.AvatarURL, &tempGroup.Status, &tempGroup.Count, &tempGroup.VisibilityID)
+		if err != nil {
+			return
+		}
+		groups = append(groups, tempGroup)
+	}
+
+	if len(groups) > listParams.Limit {
+		groups = groups[:listParams.Limit]
+		last := groups[len(groups)-1]
+		nextCursor = encodeCursor(listCursor{
+			SortBy: listParams.SortBy,
+			Desc:   listParams.Desc,
+			Key:    strconv.Itoa(last.Count),
+			ID:     last.ID,
+		})
+	}
+	return
+}
+
+// SearchGroups ищет по groups.search_vector; tsQuery должен быть готовым выражением для to_tsquery.
+// Участникам группа находится при любой видимости, остальным - только публичная.
+func (s *storage) SearchGroups(userID int, tsQuery, scope string, limit, offset int) (groups []models2.GroupPreview, err error) {
+	const sqlQuery = `
+	SELECT g.id,
+		   g.title,
+		   g.description,
+		   g.url,
+		   g.avatar_url,
+		   COALESCE(r.id, 0),
+		   COALESCE(r.title, ''),
+		   g.status_id,
+		   g.members,
+		   g.visibility_id
+	FROM groups AS g
+			 LEFT JOIN users_groups AS ug ON g.id = ug.group_id AND ug.user_id = $1
+			 LEFT JOIN roles AS r ON ug.role_id = r.id,
+		 to_tsquery('simple', $2) AS q
+	WHERE g.status_id = 1 AND g.search_vector @@ q`
+	params := []interface{}{
+		userID,
+		tsQuery,
+	}
+	query := sqlQuery
+	switch scope {
+	case models.SearchScopeMember:
+		query += ` AND ug.user_id IS NOT NULL`
+	case models.SearchScopePublic:
+		query += ` AND g.visibility_id = $3`
+		params = append(params, models2.VisibilityPublic)
+	default:
+		query += ` AND (ug.user_id IS NOT NULL OR g.visibility_id = $3)`
+		params = append(params, models2.VisibilityPublic)
+	}
+	query += fmt.Sprintf(`
+	ORDER BY ts_rank(g.search_vector, q) DESC, g.members DESC, g.id
+	LIMIT $%d OFFSET $%d;`, len(params)+1, len(params)+2)
+	params = append(params, limit, offset)
+
+	groups = make([]models2.GroupPreview, 0)
+	rows, err := s.conn().Query(query, params...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tempGroup models2.GroupPreview
+		err = rows.Scan(&tempGroup.ID, &tempGroup.Title, &tempGroup.Description, &tempGroup.URL, &tempGroup.AvatarURL,
+			&tempGroup.UserRole.RoleID, &tempGroup.UserRole.RoleName, &tempGroup.Status, &tempGroup.Count, &tempGroup.VisibilityID)
+		if err != nil {
+			return
+		}
+		tempGroup.UserID = userID
+		if tempGroup.UserRole.RoleID != 0 {
+			tempGroup.UserRole.UserID = userID
+			tempGroup.UserRole.GroupID = tempGroup.ID
+		}
 		groups = append(groups, tempGroup)
 	}
 	return
 }
 
-func (s *storage) HashToGroupID(line string) (groupID int, err error) {
-	const sqlTemplate = `SELECT group_id from %s WHERE link=$1`
+func (s *storage) SelectLinkByHash(line string) (link models2.GroupInviteLink, err error) {
+	const sqlTemplate = `
+	SELECT link, group_id, created, author, role_id, expires_at, max_uses, uses
+	FROM %s
+	WHERE link=$1`
 	query := fmt.Sprintf(sqlTemplate, groupLinksTable)
 
-	row := s.db.QueryRow(query, line)
-	if row == nil {
-		err = fmt.Errorf("nil row")
+	var expiresAt sql.NullTime
+	err = s.conn().QueryRow(query, line).Scan(&link.Link, &link.GroupID, &link.Added, &link.Author.ID, &link.RoleID,
+		&expiresAt, &link.MaxUses, &link.Uses)
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	return
+}
+
+func (s *storage) UseLink(line string) (err error) {
+	const sqlTemplate = `
+	UPDATE %s SET uses = uses + 1
+	WHERE link=$1
+	  AND (max_uses = 0 OR uses < max_uses)
+	  AND (expires_at IS NULL OR expires_at > now())`
+	query := fmt.Sprintf(sqlTemplate, groupLinksTable)
+
+	res, err := s.conn().Exec(query, line)
+	if err != nil {
+		return
+	}
+	c, err := res.RowsAffected()
+	if err != nil {
 		return
 	}
-	err = row.Scan(&groupID)
+	if c < 1 {
+		err = sql.ErrNoRows
+	}
 	return
 }
 
-func (s *storage) AddShortLinkToGroup(groupID int, link string, author int) (err error) {
-	const sqlTemplate = `INSERT INTO %s (group_id, link, author) VALUES ($1, $2, $3)`
+func (s *storage) AddShortLinkToGroup(groupID int, link models2.GroupInviteLink) (err error) {
+	const sqlTemplate = `
+	INSERT INTO %s (group_id, link, author, role_id, expires_at, max_uses)
+	VALUES ($1, $2, $3, $4, $5, $6)`
 	query := fmt.Sprintf(sqlTemplate, groupLinksTable)
 
-	res, err := s.db.Exec(query, groupID, link, author)
+	res, err := s.conn().Exec(query, groupID, link.Link, link.Author.ID, link.RoleID, link.ExpiresAt, link.MaxUses)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == pgErrorUniqueConstraint {
+		return models.ErrorDuplicate
+	}
 	if err != nil {
 		return err
 	}
@@ -272,20 +757,28 @@ func (s *storage) AddShortLinkToGroup(groupID int, link string, author int) (err
 }
 
 func (s *storage) ListShortLinksToGroup(groupID int) (res []models2.GroupInviteLink, err error) {
-	const sqlTemplate = `SELECT link, created, author from %s WHERE group_id=$1`
+	const sqlTemplate = `
+	SELECT link, created, author, role_id, expires_at, max_uses, uses
+	FROM %s
+	WHERE group_id=$1`
 	query := fmt.Sprintf(sqlTemplate, groupLinksTable)
 
-	rows, err := s.db.Query(query, groupID)
+	rows, err := s.conn().Query(query, groupID)
 	if err != nil {
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		link := models2.GroupInviteLink{}
-		err = rows.Scan(&link.Link, &link.Added, &link.Author.ID)
+		link := models2.GroupInviteLink{GroupID: groupID}
+		var expiresAt sql.NullTime
+		err = rows.Scan(&link.Link, &link.Added, &link.Author.ID, &link.RoleID, &expiresAt, &link.MaxUses, &link.Uses)
 		if err != nil {
 			return
 		}
+		if expiresAt.Valid {
+			link.ExpiresAt = &expiresAt.Time
+		}
 		res = append(res, link)
 	}
 	return
@@ -295,7 +788,7 @@ func (s *storage) RemoveLinkToGroup(groupID int, link string) (err error) {
 	const sqlTemplate = `DELETE FROM %s WHERE group_id=$1 AND link=$2`
 	query := fmt.Sprintf(sqlTemplate, groupLinksTable)
 
-	res, err := s.db.Exec(query, groupID, link)
+	res, err := s.conn().Exec(query, groupID, link)
 	if err != nil {
 		return
 	}
@@ -308,3 +801,730 @@ func (s *storage) RemoveLinkToGroup(groupID int, link string) (err error) {
 	}
 	return
 }
+
+func (s *storage) SelectRoles(groupID int) (roles []models2.Role, err error) {
+	const sqlQuery = `
+	SELECT r.id, COALESCE(r.group_id, 0), r.title, r.rank,
+		   COALESCE(array_agg(p.action_id) FILTER (WHERE p.action_id IS NOT NULL), '{}')
+	FROM roles AS r
+			 LEFT JOIN permission AS p ON p.role_id = r.id
+	WHERE r.group_id IS NULL OR r.group_id = $1
+	GROUP BY r.id
+	ORDER BY r.id;`
+
+	roles = make([]models2.Role, 0)
+	rows, err := s.conn().Query(sqlQuery, groupID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role models2.Role
+		var actions []int64
+		err = rows.Scan(&role.ID, &role.GroupID, &role.Title, &role.Rank, pq.Array(&actions))
+		if err != nil {
+			return
+		}
+		role.Actions = toIntSlice(actions)
+		roles = append(roles, role)
+	}
+	return
+}
+
+func (s *storage) SelectRole(groupID, roleID int) (role models2.Role, err error) {
+	const sqlQuery = `
+	SELECT r.id, COALESCE(r.group_id, 0), r.title, r.rank,
+		   COALESCE(array_agg(p.action_id) FILTER (WHERE p.action_id IS NOT NULL), '{}')
+	FROM roles AS r
+			 LEFT JOIN permission AS p ON p.role_id = r.id
+	WHERE r.id = $1 AND (r.group_id IS NULL OR r.group_id = $2)
+	GROUP BY r.id;`
+
+	var actions []int64
+	err = s.conn().QueryRow(sqlQuery, roleID, groupID).Scan(&role.ID, &role.GroupID, &role.Title, &role.Rank, pq.Array(&actions))
+	role.Actions = toIntSlice(actions)
+	return
+}
+
+func (s *storage) InsertRole(role models2.Role) (roleReturn models2.Role, err error) {
+	const sqlQuery = `
+	INSERT INTO roles(title, group_id, rank)
+	VALUES ($1, $2, $3)
+	RETURNING id;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	err = tx.QueryRow(sqlQuery, role.Title, role.GroupID, role.Rank).Scan(&role.ID)
+	if err != nil {
+		return
+	}
+
+	err = insertPermissions(tx, role.ID, role.Actions)
+	return role, err
+}
+
+func (s *storage) UpdateRole(role models2.Role) (roleReturn models2.Role, err error) {
+	const sqlQuery = `
+	UPDATE roles
+	SET title = $1,
+		rank = $2
+	WHERE id = $3 AND group_id = $4;`
+
+	const sqlQueryClean = `
+	DELETE FROM %s WHERE role_id = $1;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	res, err := tx.Exec(sqlQuery, role.Title, role.Rank, role.ID, role.GroupID)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		err = sql.ErrNoRows
+		return
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(sqlQueryClean, permissionTable), role.ID)
+	if err != nil {
+		return
+	}
+
+	err = insertPermissions(tx, role.ID, role.Actions)
+	return role, err
+}
+
+func (s *storage) DeleteRole(groupID, roleID int) (err error) {
+	const sqlQueryPermission = `
+	DELETE FROM %s WHERE role_id = $1;`
+
+	const sqlQuery = `
+	DELETE FROM %s WHERE id = $1 AND group_id = $2;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	_, err = tx.Exec(fmt.Sprintf(sqlQueryPermission, permissionTable), roleID)
+	if err != nil {
+		return
+	}
+
+	res, err := tx.Exec(fmt.Sprintf(sqlQuery, rolesTable), roleID, groupID)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+func (s *storage) CountUsersWithRole(groupID, roleID int) (count int, err error) {
+	const sqlQuery = `
+	SELECT count(*)
+	FROM users_groups
+	WHERE group_id = $1 AND role_id = $2;`
+
+	err = s.conn().QueryRow(sqlQuery, groupID, roleID).Scan(&count)
+	return
+}
+
+// CountRoleReferences считает всё, что ссылается на роль: участников, приглашения, пригласительные ссылки
+// и настройку группы join_role_id.
+func (s *storage) CountRoleReferences(groupID, roleID int) (count int, err error) {
+	const sqlQuery = `
+	SELECT (SELECT count(*) FROM %[1]s WHERE group_id = $1 AND role_id = $2) +
+		   (SELECT count(*) FROM %[2]s WHERE group_id = $1 AND role_id = $2) +
+		   (SELECT count(*) FROM %[3]s WHERE group_id = $1 AND role_id = $2) +
+		   (SELECT count(*) FROM groups WHERE id = $1 AND join_role_id = $2);`
+
+	query := fmt.Sprintf(sqlQuery, userGroupsTable, invitationsTable, groupLinksTable)
+	err = s.conn().QueryRow(query, groupID, roleID).Scan(&count)
+	return
+}
+
+func insertPermissions(tx *sql.Tx, roleID int, actions []int) (err error) {
+	const sqlQuery = `
+	INSERT INTO %s(action_id, role_id)
+	SELECT unnest($1::int[]), $2;`
+
+	if len(actions) == 0 {
+		return
+	}
+	_, err = tx.Exec(fmt.Sprintf(sqlQuery, permissionTable), pq.Array(actions), roleID)
+	return
+}
+
+func toIntSlice(src []int64) (dst []int) {
+	dst = make([]int, 0, len(src))
+	for _, item := range src {
+		dst = append(dst, int(item))
+	}
+	return
+}
+
+func (s *storage) InsertTransfer(transfer models2.OwnershipTransfer) (transferReturn models2.OwnershipTransfer, err error) {
+	const sqlQueryCancel = `
+	UPDATE %s SET status_id = $1 WHERE group_id = $2 AND status_id = $3;`
+
+	const sqlQuery = `
+	INSERT INTO %s(group_id, from_user, to_user, expires_at, status_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, create_at;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	_, err = tx.Exec(fmt.Sprintf(sqlQueryCancel, groupTransfersTable), transferStatusCancelled, transfer.GroupID, transferStatusPending)
+	if err != nil {
+		return
+	}
+
+	err = tx.QueryRow(fmt.Sprintf(sqlQuery, groupTransfersTable), transfer.GroupID, transfer.FromUserID, transfer.ToUserID,
+		transfer.ExpiresAt, transferStatusPending).Scan(&transfer.ID, &transfer.CreateAt)
+	return transfer, err
+}
+
+func (s *storage) SelectTransfer(transferID int) (transfer models2.OwnershipTransfer, err error) {
+	const sqlQuery = `
+	SELECT id, group_id, from_user, to_user, create_at, expires_at
+	FROM %s
+	WHERE id = $1 AND status_id = $2 AND expires_at > now();`
+
+	err = s.conn().QueryRow(fmt.Sprintf(sqlQuery, groupTransfersTable), transferID, transferStatusPending).
+		Scan(&transfer.ID, &transfer.GroupID, &transfer.FromUserID, &transfer.ToUserID, &transfer.CreateAt, &transfer.ExpiresAt)
+	return
+}
+
+func (s *storage) SelectTransfersByUserID(userID int) (transfers []models2.OwnershipTransfer, err error) {
+	const sqlQuery = `
+	SELECT id, group_id, from_user, to_user, create_at, expires_at
+	FROM %s
+	WHERE (from_user = $1 OR to_user = $1) AND status_id = $2 AND expires_at > now()
+	ORDER BY create_at DESC;`
+
+	transfers = make([]models2.OwnershipTransfer, 0)
+	rows, err := s.conn().Query(fmt.Sprintf(sqlQuery, groupTransfersTable), userID, transferStatusPending)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transfer models2.OwnershipTransfer
+		err = rows.Scan(&transfer.ID, &transfer.GroupID, &transfer.FromUserID, &transfer.ToUserID, &transfer.CreateAt, &transfer.ExpiresAt)
+		if err != nil {
+			return
+		}
+		transfers = append(transfers, transfer)
+	}
+	return
+}
+
+func (s *storage) CancelTransfer(transferID int) (err error) {
+	const sqlQuery = `
+	UPDATE %s SET status_id = $1 WHERE id = $2 AND status_id = $3;`
+
+	res, err := s.conn().Exec(fmt.Sprintf(sqlQuery, groupTransfersTable), transferStatusCancelled, transferID, transferStatusPending)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+// AcceptTransfer передаёт группу, только если она не удалена, а предложивший передачу всё ещё её создатель.
+// Иначе возвращается models.ErrorStaleTransfer. Условия проверяются в тех же UPDATE, что и меняют строки,
+// поэтому параллельное удаление группы или смена роли не проходят между проверкой и записью.
+func (s *storage) AcceptTransfer(transfer models2.OwnershipTransfer, creatorRoleID, adminRoleID int) (err error) {
+	const sqlQueryTransfer = `
+	UPDATE %s SET status_id = $1
+	WHERE id = $2 AND status_id = $3 AND expires_at > now();`
+
+	const sqlQueryGroup = `
+	UPDATE groups SET create_by = $1 WHERE id = $2 AND status_id = $3;`
+
+	const sqlQueryProposer = `
+	UPDATE %s SET role_id = $1 WHERE group_id = $2 AND user_id = $3 AND role_id = $4;`
+
+	const sqlQueryRole = `
+	UPDATE %s SET role_id = $1 WHERE group_id = $2 AND user_id = $3;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	res, err := tx.Exec(fmt.Sprintf(sqlQueryTransfer, groupTransfersTable), transferStatusAccepted, transfer.ID, transferStatusPending)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		return sql.ErrNoRows
+	}
+
+	res, err = tx.Exec(sqlQueryGroup, transfer.ToUserID, transfer.GroupID, models2.GroupActive)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		return models.ErrorStaleTransfer
+	}
+
+	res, err = tx.Exec(fmt.Sprintf(sqlQueryProposer, userGroupsTable), adminRoleID, transfer.GroupID, transfer.FromUserID,
+		creatorRoleID)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		return models.ErrorStaleTransfer
+	}
+
+	res, err = tx.Exec(fmt.Sprintf(sqlQueryRole, userGroupsTable), creatorRoleID, transfer.GroupID, transfer.ToUserID)
+	if err != nil {
+		return
+	}
+	if c, err2 := res.RowsAffected(); err2 == nil && c < 1 {
+		err = fmt.Errorf("new owner is not a member")
+	}
+	return
+}
+
+func (s *storage) InsertJoinRequest(groupID, userID int) (request models2.JoinRequest, err error) {
+	const sqlQuery = `
+	INSERT INTO %s(group_id, user_id, status_id)
+	VALUES ($1, $2, $3)
+	RETURNING id, group_id, user_id, status_id, create_at;`
+
+	err = s.conn().QueryRow(fmt.Sprintf(sqlQuery, joinRequestsTable), groupID, userID, models2.JoinRequestPending).
+		Scan(&request.ID, &request.GroupID, &request.UserID, &request.StatusID, &request.CreateAt)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == pgErrorUniqueConstraint {
+		err = models.ErrorDuplicate
+	}
+	return
+}
+
+func (s *storage) SelectJoinRequests(groupID int) (requests []models2.JoinRequest, err error) {
+	const sqlQuery = `
+	SELECT id, group_id, user_id, status_id, create_at, COALESCE(decided_by, 0), decided_at
+	FROM %s
+	WHERE group_id = $1 AND status_id = $2
+	ORDER BY create_at;`
+
+	return s.selectJoinRequests(fmt.Sprintf(sqlQuery, joinRequestsTable), groupID, models2.JoinRequestPending)
+}
+
+func (s *storage) SelectJoinRequestsByUserID(userID int) (requests []models2.JoinRequest, err error) {
+	const sqlQuery = `
+	SELECT id, group_id, user_id, status_id, create_at, COALESCE(decided_by, 0), decided_at
+	FROM %s
+	WHERE user_id = $1
+	ORDER BY create_at DESC;`
+
+	return s.selectJoinRequests(fmt.Sprintf(sqlQuery, joinRequestsTable), userID)
+}
+
+func (s *storage) selectJoinRequests(query string, params ...interface{}) (requests []models2.JoinRequest, err error) {
+	requests = make([]models2.JoinRequest, 0)
+	rows, err := s.conn().Query(query, params...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var request models2.JoinRequest
+		var decidedAt sql.NullTime
+		err = rows.Scan(&request.ID, &request.GroupID, &request.UserID, &request.StatusID, &request.CreateAt,
+			&request.DecidedBy, &decidedAt)
+		if err != nil {
+			return
+		}
+		if decidedAt.Valid {
+			request.DecidedAt = &decidedAt.Time
+		}
+		requests = append(requests, request)
+	}
+	return
+}
+
+func (s *storage) ApproveJoinRequest(groupID, requestID, roleID, actorID int) (request models2.JoinRequest, err error) {
+	const sqlQueryInsert = `
+	INSERT INTO %s(group_id, user_id, role_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	request, err = decideJoinRequest(tx, groupID, requestID, actorID, models2.JoinRequestApproved)
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(sqlQueryInsert, userGroupsTable), groupID, request.UserID, roleID)
+	return
+}
+
+func (s *storage) RejectJoinRequest(groupID, requestID, actorID int) (request models2.JoinRequest, err error) {
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	request, err = decideJoinRequest(tx, groupID, requestID, actorID, models2.JoinRequestRejected)
+	return
+}
+
+func decideJoinRequest(tx *sql.Tx, groupID, requestID, actorID, statusID int) (request models2.JoinRequest, err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		decided_by = $2,
+		decided_at = now()
+	WHERE id = $3 AND group_id = $4 AND status_id = $5
+	RETURNING id, group_id, user_id, status_id, create_at, decided_by, decided_at;`
+
+	var decidedAt time.Time
+	err = tx.QueryRow(fmt.Sprintf(sqlQuery, joinRequestsTable), statusID, actorID, requestID, groupID, models2.JoinRequestPending).
+		Scan(&request.ID, &request.GroupID, &request.UserID, &request.StatusID, &request.CreateAt, &request.DecidedBy, &decidedAt)
+	request.DecidedAt = &decidedAt
+	return
+}
+
+func (s *storage) InsertInvitation(invitation models2.Invitation) (invitationReturn models2.Invitation, err error) {
+	const sqlQuery = `
+	INSERT INTO %s(group_id, user_id, role_id, invited_by, status_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, status_id, create_at;`
+
+	err = s.conn().QueryRow(fmt.Sprintf(sqlQuery, invitationsTable), invitation.GroupID, invitation.UserID, invitation.RoleID,
+		invitation.InvitedBy, models2.InvitationPending).Scan(&invitation.ID, &invitation.StatusID, &invitation.CreateAt)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == pgErrorUniqueConstraint {
+		err = models.ErrorDuplicate
+	}
+	return invitation, err
+}
+
+func (s *storage) SelectInvitationsByUserID(userID int) (invitations []models2.Invitation, err error) {
+	const sqlQuery = `
+	SELECT i.id, i.group_id, g.title, i.user_id, i.role_id, r.title, i.invited_by, i.status_id, i.create_at, i.decided_at
+	FROM %s AS i
+			 JOIN groups AS g ON i.group_id = g.id
+			 JOIN roles AS r ON i.role_id = r.id
+	WHERE i.user_id = $1 AND i.status_id = $2 AND g.status_id = 1
+	ORDER BY i.create_at DESC;`
+
+	return s.selectInvitations(fmt.Sprintf(sqlQuery, invitationsTable), userID, models2.InvitationPending)
+}
+
+func (s *storage) SelectInvitationsByGroupID(groupID int) (invitations []models2.Invitation, err error) {
+	const sqlQuery = `
+	SELECT i.id, i.group_id, g.title, i.user_id, i.role_id, r.title, i.invited_by, i.status_id, i.create_at, i.decided_at,
+		   o.status_id, o.attempts, o.last_error, o.sent_at, i.delivery_error
+	FROM %s AS i
+			 JOIN groups AS g ON i.group_id = g.id
+			 JOIN roles AS r ON i.role_id = r.id
+			 LEFT JOIN email_outbox AS o ON i.message_id = o.id
+	WHERE i.group_id = $1 AND i.status_id = $2
+	ORDER BY i.create_at;`
+
+	invitations = make([]models2.Invitation, 0)
+	rows, err := s.conn().Query(fmt.Sprintf(sqlQuery, invitationsTable), groupID, models2.InvitationPending)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var invitation models2.Invitation
+		var decidedAt, sentAt sql.NullTime
+		var messageStatusID, attempts sql.NullInt64
+		var lastError, deliveryError sql.NullString
+		err = rows.Scan(&invitation.ID, &invitation.GroupID, &invitation.GroupTitle, &invitation.UserID, &invitation.RoleID,
+			&invitation.RoleName, &invitation.InvitedBy, &invitation.StatusID, &invitation.CreateAt, &decidedAt,
+			&messageStatusID, &attempts, &lastError, &sentAt, &deliveryError)
+		if err != nil {
+			return
+		}
+		if decidedAt.Valid {
+			invitation.DecidedAt = &decidedAt.Time
+		}
+		if messageStatusID.Valid {
+			invitation.Delivery = &models2.InvitationDelivery{
+				StatusID:  deliveryStatus(int(messageStatusID.Int64)),
+				Attempts:  int(attempts.Int64),
+				LastError: lastError.String,
+			}
+			if sentAt.Valid {
+				invitation.Delivery.SentAt = &sentAt.Time
+			}
+		}
+		// Письмо не попало в очередь: ни при первой отправке, ни при повторной.
+		if deliveryError.Valid {
+			invitation.Delivery = &models2.InvitationDelivery{StatusID: models2.DeliveryFailed, LastError: deliveryError.String}
+		}
+		invitations = append(invitations, invitation)
+	}
+	return
+}
+
+// deliveryStatus сводит статусы очереди писем к тому, что показывается администратору группы.
+func deliveryStatus(outboxStatusID int) int {
+	switch outboxStatusID {
+	case models.OutboxSent:
+		return models2.DeliverySent
+	case models.OutboxDead:
+		return models2.DeliveryFailed
+	default:
+		return models2.DeliveryQueued
+	}
+}
+
+func (s *storage) selectInvitations(query string, params ...interface{}) (invitations []models2.Invitation, err error) {
+	invitations = make([]models2.Invitation, 0)
+	rows, err := s.conn().Query(query, params...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var invitation models2.Invitation
+		var decidedAt sql.NullTime
+		err = rows.Scan(&invitation.ID, &invitation.GroupID, &invitation.GroupTitle, &invitation.UserID, &invitation.RoleID,
+			&invitation.RoleName, &invitation.InvitedBy, &invitation.StatusID, &invitation.CreateAt, &decidedAt)
+		if err != nil {
+			return
+		}
+		if decidedAt.Valid {
+			invitation.DecidedAt = &decidedAt.Time
+		}
+		invitations = append(invitations, invitation)
+	}
+	return
+}
+
+func (s *storage) AcceptInvitation(invitationID, userID int) (invitation models2.Invitation, err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		decided_at = now()
+	WHERE id = $2 AND user_id = $3 AND status_id = $4
+	RETURNING id, group_id, user_id, role_id, invited_by, status_id, create_at, decided_at;`
+
+	const sqlQueryInsert = `
+	INSERT INTO %s(group_id, user_id, role_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;`
+
+	tx, finish, err := s.begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = finish(err)
+	}()
+
+	invitation, err = scanInvitation(tx.QueryRow(fmt.Sprintf(sqlQuery, invitationsTable),
+		models2.InvitationAccepted, invitationID, userID, models2.InvitationPending))
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(sqlQueryInsert, userGroupsTable), invitation.GroupID, invitation.UserID, invitation.RoleID)
+	return
+}
+
+func (s *storage) DeclineInvitation(invitationID, userID int) (invitation models2.Invitation, err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		decided_at = now()
+	WHERE id = $2 AND user_id = $3 AND status_id = $4
+	RETURNING id, group_id, user_id, role_id, invited_by, status_id, create_at, decided_at;`
+
+	return scanInvitation(s.conn().QueryRow(fmt.Sprintf(sqlQuery, invitationsTable),
+		models2.InvitationDeclined, invitationID, userID, models2.InvitationPending))
+}
+
+func (s *storage) RevokeInvitation(groupID, invitationID int) (invitation models2.Invitation, err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		decided_at = now()
+	WHERE id = $2 AND group_id = $3 AND status_id = $4
+	RETURNING id, group_id, user_id, role_id, invited_by, status_id, create_at, decided_at;`
+
+	return scanInvitation(s.conn().QueryRow(fmt.Sprintf(sqlQuery, invitationsTable),
+		models2.InvitationRevoked, invitationID, groupID, models2.InvitationPending))
+}
+
+func (s *storage) SelectInvitation(invitationID int) (invitation models2.Invitation, err error) {
+	const sqlQuery = `
+	SELECT id, group_id, user_id, role_id, invited_by, status_id, create_at, decided_at
+	FROM %s
+	WHERE id = $1;`
+
+	return scanInvitation(s.conn().QueryRow(fmt.Sprintf(sqlQuery, invitationsTable), invitationID))
+}
+
+// SelectInvitationByLink ищет приглашение, ссылка на которое ушла в последнем письме, в любом статусе.
+func (s *storage) SelectInvitationByLink(link string) (invitation models2.Invitation, err error) {
+	const sqlQuery = `
+	SELECT id, group_id, user_id, role_id, invited_by, status_id, create_at, decided_at
+	FROM %s
+	WHERE link = $1;`
+
+	return scanInvitation(s.conn().QueryRow(fmt.Sprintf(sqlQuery, invitationsTable), link))
+}
+
+// SetInvitationMessage привязывает к приглашению письмо и ссылку из него и возвращает ссылку из предыдущего письма.
+func (s *storage) SetInvitationMessage(invitationID, messageID int, link string) (previousLink string, err error) {
+	const sqlQuery = `
+	UPDATE %[1]s AS i
+	SET message_id = $1, link = $2, delivery_error = NULL
+	FROM (SELECT id, link FROM %[1]s WHERE id = $3 FOR UPDATE) AS previous
+	WHERE i.id = previous.id
+	RETURNING previous.link;`
+
+	var previous sql.NullString
+	err = s.conn().QueryRow(fmt.Sprintf(sqlQuery, invitationsTable), messageID, link, invitationID).Scan(&previous)
+	return previous.String, err
+}
+
+// SetInvitationDeliveryError отмечает, что письмо с приглашением не удалось поставить в очередь.
+func (s *storage) SetInvitationDeliveryError(invitationID int, reason string) (err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET delivery_error = $1
+	WHERE id = $2;`
+
+	_, err = s.conn().Exec(fmt.Sprintf(sqlQuery, invitationsTable), reason, invitationID)
+	return
+}
+
+func scanInvitation(row *sql.Row) (invitation models2.Invitation, err error) {
+	var decidedAt sql.NullTime
+	err = row.Scan(&invitation.ID, &invitation.GroupID, &invitation.UserID, &invitation.RoleID, &invitation.InvitedBy,
+		&invitation.StatusID, &invitation.CreateAt, &decidedAt)
+	if decidedAt.Valid {
+		invitation.DecidedAt = &decidedAt.Time
+	}
+	return
+}
+
+// InsertAuditEntry пишет в журнал аудита. Вызывается внутри WithTx вместе с самим изменением.
+func (s *storage) InsertAuditEntry(entry models2.AuditEntry) (err error) {
+	const sqlQuery = `
+	INSERT INTO %s(group_id, actor_id, action, target_type, target_id, before, after, request_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	_, err = s.conn().Exec(fmt.Sprintf(sqlQuery, auditTable), entry.GroupID, entry.ActorID, entry.Action, entry.TargetType,
+		entry.TargetID, nullJSON(entry.Before), nullJSON(entry.After), entry.RequestID)
+	return
+}
+
+func nullJSON(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
+
+var auditSortKey = sortKey{expr: "a.id", cast: "int"}
+
+func (s *storage) SelectAuditEntries(request models.AuditListRequest) (entries []models2.AuditEntry, page models.Page, err error) {
+	const sqlQuery = `
+	SELECT a.id, a.group_id, a.actor_id, a.action, a.target_type, a.target_id, a.before, a.after, a.request_id, a.create_at
+	FROM %s AS a
+	WHERE a.group_id = $1`
+	params := []interface{}{
+		request.Group,
+	}
+	query := fmt.Sprintf(sqlQuery, auditTable)
+	if request.ActorID != 0 {
+		query += fmt.Sprintf(` AND a.actor_id = $%d`, len(params)+1)
+		params = append(params, request.ActorID)
+	}
+	if request.Action != "" {
+		query += fmt.Sprintf(` AND a.action = $%d`, len(params)+1)
+		params = append(params, request.Action)
+	}
+
+	entries = make([]models2.AuditEntry, 0)
+	err = s.conn().QueryRow(countQuery(query), params...).Scan(&page.Total)
+	if err != nil {
+		return
+	}
+
+	query, params, err = appendKeyset(query, params, auditSortKey, "a.id", request.ListParams)
+	if err != nil {
+		return
+	}
+
+	rows, err := s.conn().Query(query, params...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models2.AuditEntry
+		var before, after []byte
+		err = rows.Scan(&entry.ID, &entry.GroupID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID,
+			&before, &after, &entry.RequestID, &entry.CreateAt)
+		if err != nil {
+			return
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+
+	if len(entries) > request.Limit {
+		entries = entries[:request.Limit]
+		last := entries[len(entries)-1]
+		page.NextCursor = encodeCursor(listCursor{
+			SortBy: request.SortBy,
+			Desc:   request.Desc,
+			Key:    strconv.Itoa(last.ID),
+			ID:     last.ID,
+		})
+	}
+	return
+}
@@ -0,0 +1,420 @@
+package groupStorage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	"github.com/Solar-2020/Group-Backend/internal/storages/migrations"
+	models2 "github.com/Solar-2020/Group-Backend/pkg/models"
+)
+
+// testDBEnv - строка подключения к отдельной тестовой базе с search_path=groups. Без неё проверяется только хранилище в памяти.
+const testDBEnv = "GROUP_TEST_DB_CONNECTION_STRING"
+
+var errRollback = errors.New("rollback")
+
+func TestMemoryStorage(t *testing.T) {
+	storageSuite(t, NewMemoryStorage())
+}
+
+func TestPostgresStorage(t *testing.T) {
+	connection := os.Getenv(testDBEnv)
+	if connection == "" {
+		t.Skipf("%s is not set", testDBEnv)
+	}
+
+	db, err := sql.Open("postgres", connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+	storageSuite(t, NewStorage(db))
+}
+
+// storageSuite проверяет поведение, на которое полагается сервис, одинаково для всех реализаций Storage.
+// Данные не удаляются, поэтому ссылки групп уникальны для каждого запуска.
+func storageSuite(t *testing.T, s Storage) {
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	slugs := 0
+	newGroup := func(t *testing.T, creatorID int) models2.Group {
+		slugs++
+		group, err := s.InsertGroup(models2.Group{
+			Title:        "Группа",
+			URL:          fmt.Sprintf("g%d-%s", slugs, suffix),
+			CreateBy:     creatorID,
+			JoinRoleID:   int(models2.RoleDweller),
+			VisibilityID: models2.VisibilityPrivate,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.InsertUser(group.ID, creatorID, int(models2.RoleCreator)); err != nil {
+			t.Fatal(err)
+		}
+		return group
+	}
+
+	t.Run("groups", func(t *testing.T) {
+		group := newGroup(t, 1)
+
+		_, err := s.InsertGroup(models2.Group{Title: "Копия", URL: group.URL, CreateBy: 2, JoinRoleID: int(models2.RoleDweller),
+			VisibilityID: models2.VisibilityPrivate})
+		if err != models.ErrorDuplicate {
+			t.Errorf("duplicate slug: got %v, want %v", err, models.ErrorDuplicate)
+		}
+
+		stored, err := s.SelectGroupByID(group.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Title != group.Title || stored.URL != group.URL || stored.Count != 1 {
+			t.Errorf("stored group %+v", stored)
+		}
+
+		deleted, err := s.UpdateGroupStatus(group.ID, models2.GroupDeleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted.StatusID != models2.GroupDeleted || deleted.DeletedAt == nil {
+			t.Errorf("deleted group %+v", deleted)
+		}
+		if _, err = s.SelectGroupByID(group.ID); err != sql.ErrNoRows {
+			t.Errorf("deleted group is visible: %v", err)
+		}
+		if groupID, err := s.SelectGroupIDBySlug(group.URL); err != nil || groupID != group.ID {
+			t.Errorf("slug of deleted group: got %d, %v", groupID, err)
+		}
+
+		restored, err := s.UpdateGroupStatus(group.ID, models2.GroupActive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.DeletedAt != nil {
+			t.Errorf("restored group keeps deleted_at %v", restored.DeletedAt)
+		}
+	})
+
+	t.Run("members", func(t *testing.T) {
+		group := newGroup(t, 1)
+
+		if err := s.InsertUser(group.ID, 2, int(models2.RoleDweller)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertUser(group.ID, 2, int(models2.RoleDweller)); err == nil {
+			t.Error("duplicate member inserted")
+		}
+
+		if role, err := s.EditUserRole(group.ID, 2, int(models2.RoleAdmin)); err != nil || role != int(models2.RoleAdmin) {
+			t.Errorf("edit role: got %d, %v", role, err)
+		}
+		userRole, err := s.SelectGroupRole(group.ID, 2)
+		if err != nil || userRole.RoleID != int(models2.RoleAdmin) {
+			t.Errorf("member role: got %+v, %v", userRole, err)
+		}
+		if count, err := s.CountUsersWithRole(group.ID, int(models2.RoleAdmin)); err != nil || count != 1 {
+			t.Errorf("admins: got %d, %v", count, err)
+		}
+
+		if err = s.RemoveUser(group.ID, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.SelectGroupRole(group.ID, 2); err != sql.ErrNoRows {
+			t.Errorf("removed member: %v", err)
+		}
+		if stored, err := s.SelectGroupByID(group.ID); err != nil || stored.Count != 1 {
+			t.Errorf("members count: got %d, %v", stored.Count, err)
+		}
+	})
+
+	t.Run("member pages", func(t *testing.T) {
+		group := newGroup(t, 1)
+		for userID := 2; userID <= 5; userID++ {
+			if err := s.InsertUser(group.ID, userID, int(models2.RoleDweller)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		params := models.ListParams{Limit: 3, SortBy: models.SortByRole, Desc: true}
+		seen := make(map[int]bool)
+		for page := 0; page < 3; page++ {
+			users, info, err := s.SelectUsersByGroupID(group.ID, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Total != 5 {
+				t.Errorf("total: got %d, want 5", info.Total)
+			}
+			for _, user := range users {
+				if seen[user.UserID] {
+					t.Errorf("user %d on two pages", user.UserID)
+				}
+				seen[user.UserID] = true
+			}
+			if info.NextCursor == "" {
+				break
+			}
+			params.Cursor = info.NextCursor
+		}
+		if len(seen) != 5 {
+			t.Errorf("listed %d members, want 5", len(seen))
+		}
+		if !seen[1] {
+			t.Error("creator is missing")
+		}
+
+		if _, _, err := s.SelectUsersByGroupID(group.ID, models.ListParams{Limit: 3, SortBy: "bad"}); err != models.ErrorBadSort {
+			t.Errorf("bad sort: got %v", err)
+		}
+	})
+
+	t.Run("public group pages", func(t *testing.T) {
+		// Группы с 3, 2 и 1 участником; в базе могут быть и другие публичные группы.
+		order := make([]int, 0)
+		for members := 3; members >= 1; members-- {
+			slugs++
+			group, err := s.InsertGroup(models2.Group{Title: "Публичная", URL: fmt.Sprintf("g%d-%s", slugs, suffix),
+				CreateBy: 1, JoinRoleID: int(models2.RoleDweller), VisibilityID: models2.VisibilityPublic})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for userID := 1; userID <= members; userID++ {
+				if err = s.InsertUser(group.ID, userID, int(models2.RoleDweller)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			order = append(order, group.ID)
+		}
+		ours := make(map[int]bool)
+		for _, id := range order {
+			ours[id] = true
+		}
+
+		params := models.ListParams{Limit: 2, SortBy: models.SortByMembers, Desc: true}
+		seen := make(map[int]bool)
+		listed := make([]int, 0)
+		for {
+			groups, nextCursor, err := s.SelectPublicGroups(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(groups) > params.Limit {
+				t.Fatalf("got %d groups, limit %d", len(groups), params.Limit)
+			}
+			for _, group := range groups {
+				if seen[group.ID] {
+					t.Fatalf("group %d on two pages", group.ID)
+				}
+				seen[group.ID] = true
+				if ours[group.ID] {
+					listed = append(listed, group.ID)
+				}
+			}
+			if nextCursor == "" {
+				break
+			}
+			params.Cursor = nextCursor
+		}
+		if fmt.Sprint(listed) != fmt.Sprint(order) {
+			t.Errorf("listed %v, want %v", listed, order)
+		}
+
+		_, _, err := s.SelectPublicGroups(models.ListParams{Limit: 2, Cursor: "bad", SortBy: models.SortByMembers, Desc: true})
+		if err != models.ErrorBadCursor {
+			t.Errorf("bad cursor: got %v", err)
+		}
+	})
+
+	t.Run("links", func(t *testing.T) {
+		group := newGroup(t, 1)
+		other := newGroup(t, 2)
+
+		line := "l" + suffix
+		link := models2.GroupInviteLink{Link: line, Author: models2.AuthorPack{ID: 1}, RoleID: int(models2.RoleDweller), MaxUses: 1}
+		if err := s.AddShortLinkToGroup(group.ID, link); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddShortLinkToGroup(group.ID, link); err != models.ErrorDuplicate {
+			t.Errorf("duplicate link: got %v", err)
+		}
+
+		stored, err := s.SelectLinkByHash(line)
+		if err != nil || stored.GroupID != group.ID || stored.MaxUses != 1 {
+			t.Errorf("stored link %+v, %v", stored, err)
+		}
+
+		if err = s.UseLink(line); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.UseLink(line); err != sql.ErrNoRows {
+			t.Errorf("exhausted link: got %v", err)
+		}
+
+		if err = s.RemoveLinkToGroup(other.ID, line); err == nil {
+			t.Error("link removed through another group")
+		}
+		if err = s.RemoveLinkToGroup(group.ID, line); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.SelectLinkByHash(line); err != sql.ErrNoRows {
+			t.Errorf("removed link: %v", err)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		group := newGroup(t, 1)
+
+		role, err := s.InsertRole(models2.Role{GroupID: group.ID, Title: "Казначей", Rank: 150,
+			Actions: []int{models2.ActionViewGroup, models2.ActionViewMembers}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := s.SelectRole(group.ID, role.ID)
+		if err != nil || stored.Rank != 150 || len(stored.Actions) != 2 {
+			t.Errorf("stored role %+v, %v", stored, err)
+		}
+		if _, err = s.SelectPermission(models2.ActionViewMembers, role.ID); err != nil {
+			t.Errorf("permission: %v", err)
+		}
+		if _, err = s.SelectPermission(models2.ActionDeleteGroup, role.ID); err != sql.ErrNoRows {
+			t.Errorf("missing permission: got %v", err)
+		}
+
+		if err = s.InsertUser(group.ID, 2, role.ID); err != nil {
+			t.Fatal(err)
+		}
+		if count, err := s.CountRoleReferences(group.ID, role.ID); err != nil || count != 1 {
+			t.Errorf("references: got %d, %v", count, err)
+		}
+		if err = s.DeleteRole(group.ID, role.ID); err == nil {
+			t.Error("assigned role deleted")
+		}
+
+		if err = s.RemoveUser(group.ID, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.DeleteRole(group.ID, role.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.SelectRole(group.ID, role.ID); err != sql.ErrNoRows {
+			t.Errorf("deleted role: %v", err)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		group := newGroup(t, 1)
+
+		err := s.WithTx(func(tx Storage) error {
+			if err := tx.InsertUser(group.ID, 2, int(models2.RoleDweller)); err != nil {
+				return err
+			}
+			// Вложенная транзакция присоединяется к внешней.
+			return tx.WithTx(func(tx Storage) error {
+				if _, err := tx.EditUserRole(group.ID, 1, int(models2.RoleAdmin)); err != nil {
+					return err
+				}
+				return errRollback
+			})
+		})
+		if err != errRollback {
+			t.Fatalf("got %v, want %v", err, errRollback)
+		}
+		if _, err = s.SelectGroupRole(group.ID, 2); err != sql.ErrNoRows {
+			t.Errorf("member inserted in rolled back transaction: %v", err)
+		}
+		if role, err := s.SelectGroupRole(group.ID, 1); err != nil || role.RoleID != int(models2.RoleCreator) {
+			t.Errorf("role changed in rolled back transaction: %+v, %v", role, err)
+		}
+
+		err = s.WithTx(func(tx Storage) error {
+			return tx.InsertUser(group.ID, 3, int(models2.RoleDweller))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.SelectGroupRole(group.ID, 3); err != nil {
+			t.Errorf("committed member: %v", err)
+		}
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		group := newGroup(t, 1)
+
+		for i, action := range []string{models2.AuditGroupUpdate, models2.AuditRoleCreate, models2.AuditGroupUpdate} {
+			err := s.InsertAuditEntry(models2.AuditEntry{
+				GroupID:    group.ID,
+				ActorID:    1,
+				Action:     action,
+				TargetType: "group",
+				TargetID:   fmt.Sprintf("%d", i),
+				After:      []byte(`{"title":"Группа"}`),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		entries, page, err := s.SelectAuditEntries(models.AuditListRequest{
+			Group:      group.ID,
+			Action:     models2.AuditGroupUpdate,
+			ListParams: models.ListParams{Limit: 10, SortBy: models.SortByCreated, Desc: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 2 || len(entries) != 2 {
+			t.Fatalf("got %d of %d entries, want 2", len(entries), page.Total)
+		}
+		if entries[0].ID < entries[1].ID || entries[0].TargetID != "2" {
+			t.Errorf("entries are not newest first: %+v", entries)
+		}
+	})
+
+	t.Run("deleted groups", func(t *testing.T) {
+		group := newGroup(t, 7)
+		if err := s.AddShortLinkToGroup(group.ID, models2.GroupInviteLink{Link: "p" + suffix, Author: models2.AuthorPack{ID: 7},
+			RoleID: int(models2.RoleDweller)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.UpdateGroupStatus(group.ID, models2.GroupDeleted); err != nil {
+			t.Fatal(err)
+		}
+
+		deleted, err := s.SelectDeletedGroups(7, int(models2.RoleCreator), time.Now().Add(-time.Hour))
+		if err != nil || len(deleted) != 1 || deleted[0].ID != group.ID {
+			t.Errorf("deleted groups %+v, %v", deleted, err)
+		}
+		if deleted, _ = s.SelectDeletedGroups(7, int(models2.RoleCreator), time.Now().Add(time.Hour)); len(deleted) != 0 {
+			t.Errorf("expired groups listed: %+v", deleted)
+		}
+
+		purged, err := s.PurgeDeletedGroups(time.Now().Add(time.Hour), 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, groupID := range purged {
+			found = found || groupID == group.ID
+		}
+		if !found {
+			t.Errorf("group %d not purged: %v", group.ID, purged)
+		}
+		if _, err = s.SelectGroupRole(group.ID, 7); err != sql.ErrNoRows {
+			t.Errorf("member of purged group: %v", err)
+		}
+		if _, err = s.SelectLinkByHash("p" + suffix); err != sql.ErrNoRows {
+			t.Errorf("link of purged group: %v", err)
+		}
+		if _, err = s.SelectGroupIDBySlug(group.URL); err != sql.ErrNoRows {
+			t.Errorf("slug of purged group: %v", err)
+		}
+	})
+}
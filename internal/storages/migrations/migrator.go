@@ -0,0 +1,240 @@
+// Package migrations хранит схему базы данных сервиса групп и применяет её версиями.
+//
+// Все таблицы лежат в схеме groups. Хранилища обращаются к таблицам без указания схемы,
+// поэтому в строке подключения сервиса должен быть задан search_path=groups.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	versionsTable = "groups.schema_migrations"
+	// advisoryLockKey не даёт нескольким экземплярам сервиса применять миграции одновременно.
+	advisoryLockKey = 7302021
+)
+
+// Migration - одна версия схемы. Up применяет изменения, Down их откатывает.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus - состояние миграции в базе.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator interface {
+	Up() (applied []Migration, err error)
+	Down(steps int) (reverted []Migration, err error)
+	Status() (statuses []MigrationStatus, err error)
+}
+
+type migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) Migrator {
+	return &migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Up применяет все ещё не применённые миграции по возрастанию версии, каждую в своей транзакции.
+func (m *migrator) Up() (applied []Migration, err error) {
+	applied = make([]Migration, 0)
+	for _, migration := range m.migrations {
+		var ok bool
+		ok, err = m.apply(migration)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d %s: %s", migration.Version, migration.Name, err)
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+	return
+}
+
+// Down откатывает steps последних применённых миграций.
+func (m *migrator) Down(steps int) (reverted []Migration, err error) {
+	reverted = make([]Migration, 0, steps)
+	for i := 0; i < steps; i++ {
+		var migration Migration
+		var ok bool
+		migration, ok, err = m.revertLast()
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d %s: %s", migration.Version, migration.Name, err)
+		}
+		if !ok {
+			return
+		}
+		reverted = append(reverted, migration)
+	}
+	return
+}
+
+func (m *migrator) Status() (statuses []MigrationStatus, err error) {
+	const sqlQuery = `
+	SELECT version, applied_at
+	FROM %s;`
+
+	err = m.inLockedTx(func(tx *sql.Tx) (err error) {
+		rows, err := tx.Query(fmt.Sprintf(sqlQuery, versionsTable))
+		if err != nil {
+			return
+		}
+		defer rows.Close()
+
+		appliedAt := make(map[int]time.Time)
+		for rows.Next() {
+			var version int
+			var at time.Time
+			err = rows.Scan(&version, &at)
+			if err != nil {
+				return
+			}
+			appliedAt[version] = at
+		}
+		if err = rows.Err(); err != nil {
+			return
+		}
+
+		statuses = make([]MigrationStatus, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if at, ok := appliedAt[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return
+	})
+	return
+}
+
+func (m *migrator) apply(migration Migration) (ok bool, err error) {
+	const sqlQueryCheck = `
+	SELECT EXISTS(SELECT 1 FROM %s WHERE version = $1);`
+
+	const sqlQueryInsert = `
+	INSERT INTO %s(version, name)
+	VALUES ($1, $2);`
+
+	err = m.inLockedTx(func(tx *sql.Tx) (err error) {
+		var exists bool
+		err = tx.QueryRow(fmt.Sprintf(sqlQueryCheck, versionsTable), migration.Version).Scan(&exists)
+		if err != nil || exists {
+			return
+		}
+
+		_, err = tx.Exec(migration.Up)
+		if err != nil {
+			return
+		}
+
+		_, err = tx.Exec(fmt.Sprintf(sqlQueryInsert, versionsTable), migration.Version, migration.Name)
+		ok = err == nil
+		return
+	})
+	return
+}
+
+func (m *migrator) revertLast() (migration Migration, ok bool, err error) {
+	const sqlQuery = `
+	SELECT version
+	FROM %s
+	ORDER BY version DESC
+	LIMIT 1;`
+
+	const sqlQueryDelete = `
+	DELETE FROM %s WHERE version = $1;`
+
+	err = m.inLockedTx(func(tx *sql.Tx) (err error) {
+		var version int
+		err = tx.QueryRow(fmt.Sprintf(sqlQuery, versionsTable)).Scan(&version)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+
+		migration, ok = m.find(version)
+		if !ok {
+			migration.Version = version
+			return fmt.Errorf("неизвестная версия схемы")
+		}
+
+		_, err = tx.Exec(migration.Down)
+		if err != nil {
+			ok = false
+			return
+		}
+
+		_, err = tx.Exec(fmt.Sprintf(sqlQueryDelete, versionsTable), version)
+		if err != nil {
+			ok = false
+		}
+		return
+	})
+	return
+}
+
+func (m *migrator) find(version int) (migration Migration, ok bool) {
+	for _, migration = range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// inLockedTx выполняет fn в транзакции под advisory-блокировкой, предварительно создав таблицу версий.
+func (m *migrator) inLockedTx(fn func(tx *sql.Tx) error) (err error) {
+	const sqlQueryLock = `
+	SELECT pg_advisory_xact_lock($1);`
+
+	const sqlQueryVersions = `
+	CREATE SCHEMA IF NOT EXISTS groups;
+	CREATE TABLE IF NOT EXISTS %s
+	(
+		version    INT PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = tx.Exec(sqlQueryLock, advisoryLockKey)
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(sqlQueryVersions, versionsTable))
+	if err != nil {
+		return
+	}
+
+	return fn(tx)
+}
@@ -0,0 +1,289 @@
+package migrations
+
+// migrations упорядочены по версии. Применённую миграцию не меняют: изменения схемы добавляются новой версией.
+var migrations = []Migration{
+	{Version: 1, Name: "groups_and_roles", Up: groupsAndRolesUp, Down: groupsAndRolesDown},
+	{Version: 2, Name: "membership_flows", Up: membershipFlowsUp, Down: membershipFlowsDown},
+	{Version: 3, Name: "email_outbox", Up: emailOutboxUp, Down: emailOutboxDown},
+	{Version: 4, Name: "group_discovery", Up: groupDiscoveryUp, Down: groupDiscoveryDown},
+	{Version: 5, Name: "audit_log", Up: auditLogUp, Down: auditLogDown},
+	{Version: 6, Name: "group_retention", Up: groupRetentionUp, Down: groupRetentionDown},
+}
+
+// Системные роли создаются с фиксированными ID, совпадающими с models.RoleCreator, RoleAdmin и RoleDweller.
+const groupsAndRolesUp = `
+CREATE SCHEMA IF NOT EXISTS groups;
+
+CREATE TABLE groups.groups
+(
+	id            SERIAL PRIMARY KEY,
+	title         TEXT        NOT NULL,
+	description   TEXT        NOT NULL DEFAULT '',
+	url           TEXT        NOT NULL DEFAULT '',
+	create_by     INT         NOT NULL,
+	create_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+	status_id     INT         NOT NULL DEFAULT 1,
+	avatar_url    TEXT        NOT NULL DEFAULT '',
+	members       INT         NOT NULL DEFAULT 0,
+	join_approval BOOLEAN     NOT NULL DEFAULT FALSE,
+	join_role_id  INT         NOT NULL DEFAULT 3
+);
+
+CREATE TABLE groups.roles
+(
+	id       SERIAL PRIMARY KEY,
+	group_id INT REFERENCES groups.groups (id) ON DELETE CASCADE,
+	title    TEXT NOT NULL,
+	rank     INT  NOT NULL DEFAULT 0
+);
+
+CREATE INDEX roles_group_id_idx ON groups.roles (group_id);
+
+CREATE TABLE groups.permission
+(
+	action_id INT NOT NULL,
+	role_id   INT NOT NULL REFERENCES groups.roles (id) ON DELETE CASCADE,
+	PRIMARY KEY (role_id, action_id)
+);
+
+CREATE TABLE groups.users_groups
+(
+	group_id  INT         NOT NULL REFERENCES groups.groups (id) ON DELETE CASCADE,
+	user_id   INT         NOT NULL,
+	role_id   INT         NOT NULL REFERENCES groups.roles (id),
+	joined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX users_groups_user_id_idx ON groups.users_groups (user_id);
+
+CREATE TABLE groups.group_links
+(
+	link       TEXT PRIMARY KEY,
+	group_id   INT         NOT NULL REFERENCES groups.groups (id) ON DELETE CASCADE,
+	created    TIMESTAMPTZ NOT NULL DEFAULT now(),
+	author     INT         NOT NULL,
+	role_id    INT         NOT NULL DEFAULT 3 REFERENCES groups.roles (id),
+	expires_at TIMESTAMPTZ,
+	max_uses   INT         NOT NULL DEFAULT 0,
+	uses       INT         NOT NULL DEFAULT 0
+);
+
+CREATE INDEX group_links_group_id_idx ON groups.group_links (group_id);
+
+-- groups.members - счётчик участников, его поддерживает триггер на users_groups.
+CREATE FUNCTION groups.count_members() RETURNS TRIGGER AS
+$$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		UPDATE groups.groups SET members = members + 1 WHERE id = NEW.group_id;
+		RETURN NEW;
+	END IF;
+	UPDATE groups.groups SET members = members - 1 WHERE id = OLD.group_id;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_groups_count_members
+	AFTER INSERT OR DELETE
+	ON groups.users_groups
+	FOR EACH ROW
+EXECUTE PROCEDURE groups.count_members();
+
+INSERT INTO groups.roles(id, group_id, title, rank)
+VALUES (1, NULL, 'Создатель', 300),
+	   (2, NULL, 'Администратор', 200),
+	   (3, NULL, 'Участник', 100);
+
+SELECT setval(pg_get_serial_sequence('groups.roles', 'id'), 100);
+
+INSERT INTO groups.permission(role_id, action_id)
+SELECT 1, unnest(ARRAY [101, 102, 103, 104, 105, 106, 107, 108, 109, 110])
+UNION ALL
+SELECT 2, unnest(ARRAY [101, 102, 104, 105, 106, 107, 108, 109, 110])
+UNION ALL
+SELECT 3, unnest(ARRAY [101, 104]);
+`
+
+const groupsAndRolesDown = `
+DROP TABLE groups.group_links;
+DROP TABLE groups.users_groups;
+DROP FUNCTION groups.count_members();
+DROP TABLE groups.permission;
+DROP TABLE groups.roles;
+DROP TABLE groups.groups;
+`
+
+// Незавершённая передача, заявка или приглашение может быть только одна: это обеспечивают частичные индексы.
+const membershipFlowsUp = `
+CREATE TABLE groups.group_transfers
+(
+	id         SERIAL PRIMARY KEY,
+	group_id   INT         NOT NULL REFERENCES groups.groups (id) ON DELETE CASCADE,
+	from_user  INT         NOT NULL,
+	to_user    INT         NOT NULL,
+	create_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	status_id  INT         NOT NULL DEFAULT 1
+);
+
+CREATE INDEX group_transfers_from_user_idx ON groups.group_transfers (from_user) WHERE status_id = 1;
+CREATE INDEX group_transfers_to_user_idx ON groups.group_transfers (to_user) WHERE status_id = 1;
+
+CREATE TABLE groups.join_requests
+(
+	id         SERIAL PRIMARY KEY,
+	group_id   INT         NOT NULL REFERENCES groups.groups (id) ON DELETE CASCADE,
+	user_id    INT         NOT NULL,
+	status_id  INT         NOT NULL DEFAULT 1,
+	create_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	decided_by INT,
+	decided_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX join_requests_pending_idx ON groups.join_requests (group_id, user_id) WHERE status_id = 1;
+CREATE INDEX join_requests_user_id_idx ON groups.join_requests (user_id);
+
+CREATE TABLE groups.invitations
+(
+	id         SERIAL PRIMARY KEY,
+	group_id   INT         NOT NULL REFERENCES groups.groups (id) ON DELETE CASCADE,
+	user_id    INT         NOT NULL,
+	role_id    INT         NOT NULL REFERENCES groups.roles (id),
+	invited_by INT         NOT NULL,
+	status_id  INT         NOT NULL DEFAULT 1,
+	create_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	decided_at TIMESTAMPTZ,
+	-- одноразовая ссылка из последнего письма с приглашением
+	link       TEXT REFERENCES groups.group_links (link) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX invitations_pending_idx ON groups.invitations (group_id, user_id) WHERE status_id = 1;
+CREATE INDEX invitations_user_id_idx ON groups.invitations (user_id) WHERE status_id = 1;
+CREATE INDEX invitations_link_idx ON groups.invitations (link);
+`
+
+const membershipFlowsDown = `
+DROP TABLE groups.invitations;
+DROP TABLE groups.join_requests;
+DROP TABLE groups.group_transfers;
+`
+
+const emailOutboxUp = `
+CREATE TABLE groups.email_outbox
+(
+	id              SERIAL PRIMARY KEY,
+	sender          TEXT        NOT NULL,
+	recipient       TEXT        NOT NULL,
+	message         BYTEA       NOT NULL,
+	status_id       INT         NOT NULL DEFAULT 1,
+	attempts        INT         NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	create_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX email_outbox_ready_idx ON groups.email_outbox (next_attempt_at) WHERE status_id IN (1, 2);
+
+-- delivery_error - почему письмо с приглашением не удалось поставить в очередь.
+ALTER TABLE groups.invitations
+	ADD COLUMN message_id     INT REFERENCES groups.email_outbox (id) ON DELETE SET NULL,
+	ADD COLUMN delivery_error TEXT;
+`
+
+const emailOutboxDown = `
+ALTER TABLE groups.invitations
+	DROP COLUMN delivery_error,
+	DROP COLUMN message_id;
+DROP TABLE groups.email_outbox;
+`
+
+// Ссылка группы уникальна среди всех групп, включая удалённые. Пустая ссылка означает, что её не задали.
+// search_vector требует PostgreSQL 12 или новее.
+const groupDiscoveryUp = `
+ALTER TABLE groups.groups
+	ADD COLUMN visibility_id INT NOT NULL DEFAULT 1;
+
+CREATE UNIQUE INDEX groups_url_idx ON groups.groups (url) WHERE url <> '';
+CREATE INDEX groups_public_idx ON groups.groups (members DESC, id) WHERE status_id = 1 AND visibility_id = 3;
+
+ALTER TABLE groups.groups
+	ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', title), 'A') ||
+		setweight(to_tsvector('simple', description), 'B')
+	) STORED;
+
+CREATE INDEX groups_search_vector_idx ON groups.groups USING GIN (search_vector);
+`
+
+const groupDiscoveryDown = `
+DROP INDEX groups.groups_search_vector_idx;
+ALTER TABLE groups.groups
+	DROP COLUMN search_vector;
+DROP INDEX groups.groups_public_idx;
+DROP INDEX groups.groups_url_idx;
+ALTER TABLE groups.groups
+	DROP COLUMN visibility_id;
+`
+
+// Журнал только пополняется: изменение и удаление записей запрещены триггером. Внешнего ключа на группу нет,
+// чтобы записи переживали окончательное удаление группы.
+const auditLogUp = `
+CREATE TABLE groups.audit_log
+(
+	id          SERIAL PRIMARY KEY,
+	group_id    INT         NOT NULL,
+	actor_id    INT         NOT NULL,
+	action      TEXT        NOT NULL,
+	target_type TEXT        NOT NULL,
+	target_id   TEXT        NOT NULL,
+	before      JSONB,
+	after       JSONB,
+	request_id  TEXT        NOT NULL DEFAULT '',
+	create_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_group_id_idx ON groups.audit_log (group_id, id DESC);
+
+CREATE FUNCTION groups.audit_log_append_only() RETURNS TRIGGER AS
+$$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+	BEFORE UPDATE OR DELETE
+	ON groups.audit_log
+	FOR EACH ROW
+EXECUTE PROCEDURE groups.audit_log_append_only();
+
+INSERT INTO groups.permission(role_id, action_id)
+VALUES (1, 111),
+	   (2, 111);
+`
+
+const auditLogDown = `
+DELETE FROM groups.permission WHERE action_id = 111;
+DROP TABLE groups.audit_log;
+DROP FUNCTION groups.audit_log_append_only();
+`
+
+// Группам, удалённым до появления deleted_at, срок хранения отсчитывается от момента миграции.
+const groupRetentionUp = `
+ALTER TABLE groups.groups
+	ADD COLUMN deleted_at TIMESTAMPTZ;
+
+UPDATE groups.groups
+SET deleted_at = now()
+WHERE status_id = 2;
+
+CREATE INDEX groups_deleted_at_idx ON groups.groups (deleted_at) WHERE status_id = 2;
+`
+
+const groupRetentionDown = `
+DROP INDEX groups.groups_deleted_at_idx;
+ALTER TABLE groups.groups
+	DROP COLUMN deleted_at;
+`
@@ -0,0 +1,113 @@
+package outboxStorage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Solar-2020/Group-Backend/internal/models"
+	"time"
+)
+
+const (
+	outboxTable = "email_outbox"
+)
+
+type Storage interface {
+	InsertMessage(message models.OutboxMessage) (messageReturn models.OutboxMessage, err error)
+	ClaimMessages(limit int, lease time.Duration) (messages []models.OutboxMessage, err error)
+	MarkSent(messageID int) (err error)
+	MarkFailed(messageID int, lastError string, nextAttemptAt time.Time) (err error)
+	MarkDead(messageID int, lastError string) (err error)
+}
+
+type storage struct {
+	db *sql.DB
+}
+
+func NewStorage(db *sql.DB) Storage {
+	return &storage{
+		db: db,
+	}
+}
+
+func (s *storage) InsertMessage(message models.OutboxMessage) (messageReturn models.OutboxMessage, err error) {
+	const sqlQuery = `
+	INSERT INTO %s(sender, recipient, message, status_id, next_attempt_at)
+	VALUES ($1, $2, $3, $4, now())
+	RETURNING id, status_id, next_attempt_at, create_at;`
+
+	err = s.db.QueryRow(fmt.Sprintf(sqlQuery, outboxTable), message.From, message.To, message.Message, models.OutboxPending).
+		Scan(&message.ID, &message.StatusID, &message.NextAttemptAt, &message.CreateAt)
+	return message, err
+}
+
+// ClaimMessages забирает готовые к отправке письма. Письмо блокируется на время lease:
+// если воркер упадёт, не успев отметить результат, письмо будет отправлено повторно.
+func (s *storage) ClaimMessages(limit int, lease time.Duration) (messages []models.OutboxMessage, err error) {
+	const sqlQuery = `
+	UPDATE %[1]s
+	SET status_id = $1,
+		attempts = attempts + 1,
+		next_attempt_at = now() + $2 * interval '1 second'
+	WHERE id IN (
+		SELECT id
+		FROM %[1]s
+		WHERE status_id IN ($3, $1) AND next_attempt_at <= now()
+		ORDER BY next_attempt_at
+		LIMIT $4
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, sender, recipient, message, status_id, attempts, COALESCE(last_error, ''), next_attempt_at, create_at;`
+
+	messages = make([]models.OutboxMessage, 0)
+	rows, err := s.db.Query(fmt.Sprintf(sqlQuery, outboxTable), models.OutboxSending, int(lease.Seconds()), models.OutboxPending, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message models.OutboxMessage
+		err = rows.Scan(&message.ID, &message.From, &message.To, &message.Message, &message.StatusID, &message.Attempts,
+			&message.LastError, &message.NextAttemptAt, &message.CreateAt)
+		if err != nil {
+			return
+		}
+		messages = append(messages, message)
+	}
+	return
+}
+
+func (s *storage) MarkSent(messageID int) (err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		last_error = NULL,
+		sent_at = now()
+	WHERE id = $2;`
+
+	_, err = s.db.Exec(fmt.Sprintf(sqlQuery, outboxTable), models.OutboxSent, messageID)
+	return
+}
+
+func (s *storage) MarkFailed(messageID int, lastError string, nextAttemptAt time.Time) (err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		last_error = $2,
+		next_attempt_at = $3
+	WHERE id = $4;`
+
+	_, err = s.db.Exec(fmt.Sprintf(sqlQuery, outboxTable), models.OutboxPending, lastError, nextAttemptAt, messageID)
+	return
+}
+
+func (s *storage) MarkDead(messageID int, lastError string) (err error) {
+	const sqlQuery = `
+	UPDATE %s
+	SET status_id = $1,
+		last_error = $2
+	WHERE id = $3;`
+
+	_, err = s.db.Exec(fmt.Sprintf(sqlQuery, outboxTable), models.OutboxDead, lastError, messageID)
+	return
+}
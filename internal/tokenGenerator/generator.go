@@ -0,0 +1,63 @@
+package tokenGenerator
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// Символы, допустимые в пути пригласительной ссылки.
+	allowedChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-"
+)
+
+type Generator interface {
+	Generate() (token string, err error)
+}
+
+type generator struct {
+	length   int
+	alphabet []rune
+	max      *big.Int
+}
+
+func NewGenerator(length int, alphabet string) (Generator, error) {
+	if length < 1 {
+		return nil, fmt.Errorf("token length must be positive, got %d", length)
+	}
+
+	chars := make([]rune, 0, len(alphabet))
+	seen := make(map[rune]bool, len(alphabet))
+	for _, char := range alphabet {
+		if !strings.ContainsRune(allowedChars, char) {
+			return nil, fmt.Errorf("token alphabet contains forbidden char %q", char)
+		}
+		if seen[char] {
+			continue
+		}
+		seen[char] = true
+		chars = append(chars, char)
+	}
+	if len(chars) < 2 {
+		return nil, fmt.Errorf("token alphabet is too short")
+	}
+
+	return &generator{
+		length:   length,
+		alphabet: chars,
+		max:      big.NewInt(int64(len(chars))),
+	}, nil
+}
+
+func (g *generator) Generate() (token string, err error) {
+	var b strings.Builder
+	for i := 0; i < g.length; i++ {
+		n, err := rand.Int(rand.Reader, g.max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(g.alphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
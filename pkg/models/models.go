@@ -1,15 +1,60 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type MemberRole int
 
 const (
-	roleCreator MemberRole = 1
-	roleAdmin              = 2
-	roleDweller            = 3
+	RoleCreator MemberRole = 1
+	RoleAdmin   MemberRole = 2
+	RoleDweller MemberRole = 3
+)
+
+// Действия сервиса групп, права на которые хранятся в таблице permission.
+// Идентификаторы до 100 зарезервированы за другими сервисами.
+const (
+	ActionViewGroup   = 101
+	ActionEditGroup   = 102
+	ActionDeleteGroup = 103
+	ActionViewMembers = 104
+	ActionInvite      = 105
+	ActionEditRole    = 106
+	ActionExpel       = 107
+	ActionManageLinks = 108
+	ActionManageRoles = 109
+	ActionManageJoins = 110
+	ActionViewAudit   = 111
+)
+
+// GroupActions - все действия сервиса групп. Ролям можно выдавать только их.
+var GroupActions = []int{
+	ActionViewGroup, ActionEditGroup, ActionDeleteGroup, ActionViewMembers, ActionInvite, ActionEditRole,
+	ActionExpel, ActionManageLinks, ActionManageRoles, ActionManageJoins, ActionViewAudit,
+}
+
+func IsGroupAction(actionID int) bool {
+	for _, action := range GroupActions {
+		if action == actionID {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	JoinRequestPending  = 1
+	JoinRequestApproved = 2
+	JoinRequestRejected = 3
+)
+
+const (
+	InvitationPending  = 1
+	InvitationAccepted = 2
+	InvitationDeclined = 3
+	InvitationRevoked  = 4
 )
 
 type Group struct {
@@ -23,8 +68,38 @@ type Group struct {
 	StatusID    int       `json:"-"`
 	Count       int       `json:"count"`
 	UserRole    UserRole  `json:"userRole"`
+	// JoinApproval включает подачу заявок на вступление вместо моментального вступления по ссылке.
+	JoinApproval bool `json:"joinApproval"`
+	JoinRoleID   int  `json:"joinRoleID"`
+	VisibilityID int  `json:"visibility"`
+	// DeletedAt заполняется только у удалённых групп.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// Статус группы. Удалённую группу можно восстановить, пока её не удалил окончательно фоновый процесс очистки.
+const (
+	GroupActive  = 1
+	GroupDeleted = 2
+)
+
+// DeletedGroup - недавно удалённая группа. RestoreUntil - момент окончательного удаления группы.
+type DeletedGroup struct {
+	ID           int       `json:"id"`
+	Title        string    `json:"title"`
+	URL          string    `json:"URL"`
+	AvatarURL    string    `json:"avatarURL"`
+	Count        int       `json:"count"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	RestoreUntil time.Time `json:"restoreUntil"`
 }
 
+// Видимость группы. Unlisted-группу видно по ссылке, но её нет в поиске групп.
+const (
+	VisibilityPrivate  = 1
+	VisibilityUnlisted = 2
+	VisibilityPublic   = 3
+)
+
 type Membership struct {
 	UserID    int    `json:"userID"`
 	GroupID   int    `json:"groupID"`
@@ -34,6 +109,12 @@ type Membership struct {
 	Name      string `json:"name" validate:"required"`
 	Surname   string `json:"surname"`
 	AvatarURL string `json:"avatarURL"`
+	// JoinedAt заполняется только у принятых участников.
+	JoinedAt *time.Time `json:"joinedAt,omitempty"`
+	// Pending выставляется у приглашённых, ещё не принявших приглашение.
+	Pending      bool                `json:"pending,omitempty"`
+	InvitationID int                 `json:"invitationID,omitempty"`
+	Delivery     *InvitationDelivery `json:"delivery,omitempty"`
 }
 
 type UserRole struct {
@@ -41,6 +122,18 @@ type UserRole struct {
 	GroupID  int    `json:"groupID"`
 	RoleID   int    `json:"roleID"`
 	RoleName string `json:"roleName"`
+	// JoinedAt заполняется только в списках групп и участников.
+	JoinedAt *time.Time `json:"joinedAt,omitempty"`
+}
+
+// Role - роль участника группы. У системных ролей (создатель, админ, участник) GroupID равен 0.
+// Участник может управлять только теми, чей ранг строго ниже его собственного.
+type Role struct {
+	ID      int    `json:"id"`
+	GroupID int    `json:"groupID"`
+	Title   string `json:"title"`
+	Rank    int    `json:"rank"`
+	Actions []int  `json:"actions"`
 }
 
 type GroupPreview struct {
@@ -53,8 +146,9 @@ type GroupPreview struct {
 	UserRole    `json:"userRole"`
 	//UserRoleID  MemberRole `json:"userRoleID"`
 	//UserRole    string     `json:"userRole"`
-	Status int `json:"status"`
-	Count  int `json:"count"`
+	Status       int `json:"status"`
+	Count        int `json:"count"`
+	VisibilityID int `json:"visibility"`
 }
 
 type AuthorPack struct {
@@ -62,10 +156,17 @@ type AuthorPack struct {
 	ID    int    `json:"id"`
 }
 
+// GroupInviteLink - пригласительная ссылка. MaxUses, равный 0, и пустой ExpiresAt означают отсутствие ограничений.
 type GroupInviteLink struct {
-	Link   string     `json:"link"`
-	Added  time.Time  `json:"added"`
-	Author AuthorPack `json:"author"`
+	Link          string     `json:"link"`
+	GroupID       int        `json:"-"`
+	Added         time.Time  `json:"added"`
+	Author        AuthorPack `json:"author"`
+	RoleID        int        `json:"roleID"`
+	ExpiresAt     *time.Time `json:"expiresAt"`
+	MaxUses       int        `json:"maxUses"`
+	Uses          int        `json:"uses"`
+	RemainingUses *int       `json:"remainingUses"`
 }
 
 type GroupAction struct {
@@ -74,3 +175,86 @@ type GroupAction struct {
 	ActionID   int    `json:"actionID"`
 	ActionPath string `json:"-"`
 }
+
+type OwnershipTransfer struct {
+	ID         int       `json:"id"`
+	GroupID    int       `json:"groupID"`
+	FromUserID int       `json:"fromUserID"`
+	ToUserID   int       `json:"toUserID"`
+	CreateAt   time.Time `json:"createAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type JoinRequest struct {
+	ID        int        `json:"id"`
+	GroupID   int        `json:"groupID"`
+	UserID    int        `json:"userID"`
+	Email     string     `json:"email,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Surname   string     `json:"surname,omitempty"`
+	StatusID  int        `json:"status"`
+	CreateAt  time.Time  `json:"createAt"`
+	DecidedBy int        `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+}
+
+type Invitation struct {
+	ID         int        `json:"id"`
+	GroupID    int        `json:"groupID"`
+	GroupTitle string     `json:"groupTitle,omitempty"`
+	UserID     int        `json:"userID"`
+	RoleID     int        `json:"roleID"`
+	RoleName   string     `json:"roleName,omitempty"`
+	InvitedBy  int        `json:"invitedBy"`
+	StatusID   int        `json:"status"`
+	CreateAt   time.Time  `json:"createAt"`
+	DecidedAt  *time.Time `json:"decidedAt,omitempty"`
+	// Delivery заполняется в списке приглашений группы, если приглашённому отправлялось письмо.
+	Delivery *InvitationDelivery `json:"delivery,omitempty"`
+}
+
+const (
+	DeliveryQueued = 1
+	DeliverySent   = 2
+	DeliveryFailed = 3
+)
+
+type InvitationDelivery struct {
+	StatusID  int        `json:"status"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"lastError,omitempty"`
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+}
+
+// Действия, которые записываются в журнал аудита группы.
+const (
+	AuditGroupUpdate      = "group.update"
+	AuditGroupDelete      = "group.delete"
+	AuditGroupRestore     = "group.restore"
+	AuditGroupPurge       = "group.purge"
+	AuditGroupTransfer    = "group.transfer"
+	AuditMemberRole       = "member.role"
+	AuditMemberExpel      = "member.expel"
+	AuditMemberJoin       = "member.join"
+	AuditLinkCreate       = "link.create"
+	AuditLinkRemove       = "link.remove"
+	AuditRoleCreate       = "role.create"
+	AuditRoleUpdate       = "role.update"
+	AuditRoleDelete       = "role.delete"
+	AuditInvitationCreate = "invitation.create"
+	AuditInvitationAccept = "invitation.accept"
+)
+
+// AuditEntry - запись журнала аудита. Before и After содержат состояние объекта до и после изменения.
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	GroupID    int             `json:"groupID"`
+	ActorID    int             `json:"actorID"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   string          `json:"targetID"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"requestID,omitempty"`
+	CreateAt   time.Time       `json:"createAt"`
+}